@@ -2,8 +2,11 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"monkey_kd/token"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Node interface {
@@ -102,6 +105,27 @@ func (returnStatement *ReturnStatement) String() string {
 	return out.String()
 }
 
+type DeferStatement struct {
+	Token      token.Token
+	Expression Expression
+}
+
+func (deferStatement *DeferStatement) statementNode() {}
+
+func (deferStatement *DeferStatement) TokenLiteral() string {
+	return deferStatement.Token.Literal
+}
+
+func (deferStatement *DeferStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(deferStatement.TokenLiteral() + " ")
+	if deferStatement.Expression != nil {
+		out.WriteString(deferStatement.Expression.String())
+	}
+	out.WriteString(";")
+	return out.String()
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -135,6 +159,73 @@ func (integerLiteral *IntegerLiteral) String() string {
 	return integerLiteral.Token.Literal
 }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (floatLiteral *FloatLiteral) expressionNode() {}
+
+func (floatLiteral *FloatLiteral) TokenLiteral() string {
+	return floatLiteral.Token.Literal
+}
+
+func (floatLiteral *FloatLiteral) String() string {
+	return floatLiteral.Token.Literal
+}
+
+// StringLiteral carries both forms of a string: Token.Literal is the raw
+// source between the quotes, escapes included, and Value is the decoded
+// string.
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (stringLiteral *StringLiteral) expressionNode() {}
+
+func (stringLiteral *StringLiteral) TokenLiteral() string {
+	return stringLiteral.Token.Literal
+}
+
+// String re-emits the literal with canonical escapes, so that it parses back
+// to the same Value whatever escapes the source used.
+func (stringLiteral *StringLiteral) String() string {
+	return Quote(stringLiteral.Value)
+}
+
+// Quote returns s as a Monkey string literal. Quotes, backslashes and the
+// usual control characters get their short escapes, other non-printable
+// characters \u escapes, and bytes that aren't valid UTF-8 \x escapes.
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); {
+		char, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case char == utf8.RuneError && size == 1:
+			fmt.Fprintf(&out, "\\x%02x", s[i])
+		case char == '"':
+			out.WriteString(`\"`)
+		case char == '\\':
+			out.WriteString(`\\`)
+		case char == '\n':
+			out.WriteString(`\n`)
+		case char == '\t':
+			out.WriteString(`\t`)
+		case char == '\r':
+			out.WriteString(`\r`)
+		case !unicode.IsPrint(char):
+			fmt.Fprintf(&out, "\\u{%x}", char)
+		default:
+			out.WriteRune(char)
+		}
+		i += size
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token
 	Operator string
@@ -177,6 +268,24 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression rebinds an existing variable: Name = Value, or for a
+// compound Operator such as "+=", Name = Name + Value. It evaluates to the
+// value assigned.
+type AssignExpression struct {
+	Token    token.Token
+	Name     *Identifier
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Name.String() + " " + ae.Operator + " " + ae.Value.String() + ")"
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -216,6 +325,43 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// WhileExpression evaluates Body for as long as Condition is truthy. Used as
+// an expression it yields the value of the last body run, or null if the
+// body never ran.
+type WhileExpression struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (we *WhileExpression) expressionNode() {}
+
+func (we *WhileExpression) TokenLiteral() string { return we.Token.Literal }
+
+func (we *WhileExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(we.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(we.Body.String())
+	return out.String()
+}
+
+// WhileStatement is a while loop standing as a statement of its own, which
+// evaluates to null rather than to the loop's last value.
+type WhileStatement struct {
+	Token token.Token
+	Loop  *WhileExpression
+}
+
+func (ws *WhileStatement) statementNode() {}
+
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+
+func (ws *WhileStatement) String() string {
+	return ws.Loop.String()
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -285,3 +431,94 @@ func (callExpression *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+}
+
+func (arrayLiteral *ArrayLiteral) expressionNode() {}
+
+func (arrayLiteral *ArrayLiteral) TokenLiteral() string {
+	return arrayLiteral.Token.Literal
+}
+
+func (arrayLiteral *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range arrayLiteral.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (indexExpression *IndexExpression) expressionNode() {}
+
+func (indexExpression *IndexExpression) TokenLiteral() string {
+	return indexExpression.Token.Literal
+}
+
+func (indexExpression *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(indexExpression.Left.String())
+	out.WriteString("[")
+	out.WriteString(indexExpression.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+type DotExpression struct {
+	Token    token.Token
+	Left     Expression
+	Property *Identifier
+}
+
+func (dotExpression *DotExpression) expressionNode() {}
+
+func (dotExpression *DotExpression) TokenLiteral() string {
+	return dotExpression.Token.Literal
+}
+
+func (dotExpression *DotExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(dotExpression.Left.String())
+	out.WriteString(".")
+	out.WriteString(dotExpression.Property.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+type HashLiteral struct {
+	Token token.Token
+	Pairs map[Expression]Expression
+	Keys  []Expression // keys of Pairs in source order
+}
+
+func (hashLiteral *HashLiteral) expressionNode() {}
+
+func (hashLiteral *HashLiteral) TokenLiteral() string {
+	return hashLiteral.Token.Literal
+}
+
+func (hashLiteral *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, key := range hashLiteral.Keys {
+		pairs = append(pairs, key.String()+":"+hashLiteral.Pairs[key].String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
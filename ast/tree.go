@@ -0,0 +1,127 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Tree renders node as an indented tree, one node per line with its children
+// indented beneath it, which makes precedence and nesting easy to see where
+// String() would flatten them. Node types it doesn't know are printed with
+// their Go type and String().
+func Tree(node Node) string {
+	var out bytes.Buffer
+	writeTree(&out, node, "", 0)
+	return out.String()
+}
+
+// writeTree writes node at the given depth. label, when set, names the role
+// the node plays in its parent, such as "condition".
+func writeTree(out *bytes.Buffer, node Node, label string, depth int) {
+	out.WriteString(strings.Repeat("  ", depth))
+	if label != "" {
+		out.WriteString(label + ": ")
+	}
+	line := func(format string, args ...interface{}) {
+		fmt.Fprintf(out, format, args...)
+		out.WriteString("\n")
+	}
+	child := func(node Node, label string) {
+		writeTree(out, node, label, depth+1)
+	}
+
+	switch node := node.(type) {
+	case nil:
+		line("<nil>")
+	case *Program:
+		line("Program")
+		for _, stmt := range node.Statements {
+			child(stmt, "")
+		}
+	case *LetStatement:
+		line("LetStatement %s", node.Name.Value)
+		child(node.Value, "")
+	case *ReturnStatement:
+		line("ReturnStatement")
+		child(node.ReturnValue, "")
+	case *DeferStatement:
+		line("DeferStatement")
+		child(node.Expression, "")
+	case *ExpressionStatement:
+		line("ExpressionStatement")
+		child(node.Expression, "")
+	case *WhileStatement:
+		line("WhileStatement")
+		child(node.Loop, "")
+	case *BlockStatement:
+		line("BlockStatement")
+		for _, stmt := range node.Statements {
+			child(stmt, "")
+		}
+	case *Identifier:
+		line("Identifier %s", node.Value)
+	case *IntegerLiteral:
+		line("IntegerLiteral %d", node.Value)
+	case *FloatLiteral:
+		line("FloatLiteral %s", node.String())
+	case *StringLiteral:
+		line("StringLiteral %s", Quote(node.Value))
+	case *Boolean:
+		line("Boolean %t", node.Value)
+	case *PrefixExpression:
+		line("PrefixExpression %s", node.Operator)
+		child(node.Right, "")
+	case *InfixExpression:
+		line("InfixExpression %s", node.Operator)
+		child(node.Left, "")
+		child(node.Right, "")
+	case *AssignExpression:
+		line("AssignExpression %s %s", node.Name.Value, node.Operator)
+		child(node.Value, "")
+	case *IfExpression:
+		line("IfExpression")
+		child(node.Condition, "condition")
+		child(node.Consequence, "consequence")
+		if node.Alternative != nil {
+			child(node.Alternative, "alternative")
+		}
+	case *WhileExpression:
+		line("WhileExpression")
+		child(node.Condition, "condition")
+		child(node.Body, "body")
+	case *FunctionLiteral:
+		params := []string{}
+		for _, param := range node.Parameters {
+			params = append(params, param.Value)
+		}
+		line("FunctionLiteral (%s)", strings.Join(params, ", "))
+		child(node.Body, "body")
+	case *CallExpression:
+		line("CallExpression")
+		child(node.Function, "function")
+		for _, arg := range node.Arguments {
+			child(arg, "argument")
+		}
+	case *ArrayLiteral:
+		line("ArrayLiteral")
+		for _, el := range node.Elements {
+			child(el, "")
+		}
+	case *IndexExpression:
+		line("IndexExpression")
+		child(node.Left, "")
+		child(node.Index, "index")
+	case *DotExpression:
+		line("DotExpression .%s", node.Property.Value)
+		child(node.Left, "")
+	case *HashLiteral:
+		line("HashLiteral")
+		for _, key := range node.Keys {
+			child(key, "key")
+			child(node.Pairs[key], "value")
+		}
+	default:
+		line("%T %s", node, node.String())
+	}
+}
@@ -0,0 +1,1312 @@
+package evaluator
+
+import (
+	"io"
+	"math"
+	"monkey_kd/ast"
+	"monkey_kd/object"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtins is shared by every evaluation and must never be mutated at run
+// time; per-program state belongs on the object.Environment. It is filled in
+// by init because some builtins call back into the evaluator.
+var builtins map[string]*object.Builtin
+
+func init() {
+	builtins = map[string]*object.Builtin{
+		"ord": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `ord` must be STRING, got %s",
+						args[0].Type())
+				}
+				if utf8.RuneCountInString(str.Value) != 1 {
+					return newError("argument to `ord` must be a single character, got %q",
+						str.Value)
+				}
+				char, _ := utf8.DecodeRuneInString(str.Value)
+				return &object.Integer{Value: int64(char)}
+			},
+		},
+		"char": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				code, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `char` must be INTEGER, got %s",
+						args[0].Type())
+				}
+				if code.Value < 0 || code.Value > utf8.MaxRune || !utf8.ValidRune(rune(code.Value)) {
+					return newError("invalid code point passed to `char`: %d", code.Value)
+				}
+				return &object.String{Value: string(rune(code.Value))}
+			},
+		},
+		"merge": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				merged := object.NewHash()
+				for _, arg := range args {
+					hash, ok := arg.(*object.Hash)
+					if !ok {
+						return newError("arguments to `merge` must be HASH, got %s",
+							arg.Type())
+					}
+					for _, hashKey := range hash.Order {
+						pair := hash.Pairs[hashKey]
+						merged.Set(pair.Key.(object.Hashable), pair.Value)
+					}
+				}
+				return merged
+			},
+		},
+		"repeat_str": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `repeat_str` must be STRING, got %s",
+						args[0].Type())
+				}
+				count, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `repeat_str` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				return repeatString(str, count)
+			},
+		},
+		"assert_eq": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if !objectsEqual(args[0], args[1]) {
+					// repr quotes strings, so 1 and "1" read differently.
+					return newError("expected %s, got %s", repr(args[1]), repr(args[0]))
+				}
+				return NULL
+			},
+		},
+		"array": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2",
+						len(args))
+				}
+				length, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("first argument to `array` must be INTEGER, got %s",
+						args[0].Type())
+				}
+				if length.Value < 0 {
+					return newError("negative array length: %d", length.Value)
+				}
+				if length.Value > maxLength {
+					return newError("array length %d exceeds %d", length.Value, maxLength)
+				}
+				var fill object.Object = NULL
+				if len(args) == 2 {
+					fill = args[1]
+				}
+				elements := make([]object.Object, length.Value)
+				for i := range elements {
+					elements[i] = fill
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"each": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if err := checkFunction("each", "second", args[1]); err != nil {
+					return err
+				}
+				callback := args[1]
+				switch coll := args[0].(type) {
+				case *object.Array:
+					for _, el := range coll.Elements {
+						result := applyFunction(callback, []object.Object{el}, env)
+						if isError(result) {
+							return result
+						}
+					}
+				case *object.Hash:
+					withKey := functionArity(callback) == 2
+					for _, hashKey := range coll.Order {
+						pair := coll.Pairs[hashKey]
+						callArgs := []object.Object{pair.Value}
+						if withKey {
+							callArgs = []object.Object{pair.Key, pair.Value}
+						}
+						result := applyFunction(callback, callArgs, env)
+						if isError(result) {
+							return result
+						}
+					}
+				default:
+					return newError("first argument to `each` must be ARRAY or HASH, got %s",
+						args[0].Type())
+				}
+				return NULL
+			},
+		},
+		"version": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 0 {
+					return newError("wrong number of arguments. got=%d, want=0",
+						len(args))
+				}
+				return &object.String{Value: Version}
+			},
+		},
+		"parse_int": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError("first argument to `parse_int` must be STRING, got %s",
+						args[0].Type())
+				}
+				base, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `parse_int` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if base.Value < 2 || base.Value > 36 {
+					return newError("base must be between 2 and 36, got %d", base.Value)
+				}
+				value, err := strconv.ParseInt(str.Value, int(base.Value), 64)
+				if err != nil {
+					return newError("could not parse %q as base %d integer",
+						str.Value, base.Value)
+				}
+				return &object.Integer{Value: value}
+			},
+		},
+		"range": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) < 1 || len(args) > 3 {
+					return newError("wrong number of arguments. got=%d, want=1 to 3",
+						len(args))
+				}
+				bounds := []int64{}
+				for _, arg := range args {
+					integer, ok := arg.(*object.Integer)
+					if !ok {
+						return newError("arguments to `range` must be INTEGER, got %s",
+							arg.Type())
+					}
+					bounds = append(bounds, integer.Value)
+				}
+				// range(end), range(start, end) or range(start, end, step);
+				// end is exclusive.
+				start, end, step := int64(0), bounds[0], int64(1)
+				if len(bounds) > 1 {
+					start, end = bounds[0], bounds[1]
+				}
+				if len(bounds) > 2 {
+					step = bounds[2]
+				}
+				if step == 0 {
+					return newError("range step must not be zero")
+				}
+				// The element count is worked out up front in unsigned
+				// arithmetic, so neither it nor i += step can overflow.
+				count := uint64(0)
+				if step > 0 && start < end {
+					count = (uint64(end)-uint64(start)-1)/uint64(step) + 1
+				} else if step < 0 && start > end {
+					count = (uint64(start)-uint64(end)-1)/uint64(-step) + 1
+				}
+				if count > maxLength {
+					return newError("range of %d elements exceeds %d", count, maxLength)
+				}
+				elements := make([]object.Object, 0, count)
+				for i := start; uint64(len(elements)) < count; i += step {
+					elements = append(elements, &object.Integer{Value: i})
+					if uint64(len(elements)) == count {
+						break
+					}
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"max_by": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return extremeBy("max_by", 1, env, args)
+			},
+		},
+		"min_by": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return extremeBy("min_by", -1, env, args)
+			},
+		},
+		"flatten": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 && len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=1 or 2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `flatten` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				// depth counts how many levels of nesting are removed; it
+				// defaults to one, and a negative depth flattens completely.
+				depth := int64(1)
+				if len(args) == 2 {
+					integer, ok := args[1].(*object.Integer)
+					if !ok {
+						return newError("second argument to `flatten` must be INTEGER, got %s",
+							args[1].Type())
+					}
+					depth = integer.Value
+				}
+				return &object.Array{Elements: flattenElements(array.Elements, depth)}
+			},
+		},
+		"between": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3",
+						len(args))
+				}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newError("arguments to `between` must be INTEGER or FLOAT, got %s",
+							arg.Type())
+					}
+				}
+				// Both bounds are inclusive: lo <= x <= hi.
+				aboveLow, _ := compareObjects(args[0], args[1])
+				belowHigh, _ := compareObjects(args[0], args[2])
+				return nativeBoolToBooleanObject(aboveLow >= 0 && belowHigh <= 0)
+			},
+		},
+		"sum": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return reduceNumbers("sum", args, 0, "+")
+			},
+		},
+		"product": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return reduceNumbers("product", args, 1, "*")
+			},
+		},
+		"avg": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				total := reduceNumbers("avg", args, 0, "+")
+				if isError(total) {
+					return total
+				}
+				count := int64(len(args[0].(*object.Array).Elements))
+				if count == 0 {
+					return newError("`avg` of empty array")
+				}
+				// Stay an integer when the mean is exact.
+				if integer, ok := total.(*object.Integer); ok && integer.Value%count == 0 {
+					return &object.Integer{Value: integer.Value / count}
+				}
+				return &object.Float{Value: toFloat(total) / float64(count)}
+			},
+		},
+		"abs": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				switch arg := args[0].(type) {
+				case *object.Integer:
+					if arg.Value == math.MinInt64 {
+						return newError("integer overflow: `abs` of %d", arg.Value)
+					}
+					if arg.Value < 0 {
+						return &object.Integer{Value: -arg.Value}
+					}
+					return arg
+				case *object.Float:
+					return &object.Float{Value: math.Abs(arg.Value)}
+				default:
+					return newError("argument to `abs` must be INTEGER or FLOAT, got %s",
+						args[0].Type())
+				}
+			},
+		},
+		"sign": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if !isNumber(args[0]) {
+					return newError("argument to `sign` must be INTEGER or FLOAT, got %s",
+						args[0].Type())
+				}
+				cmp, _ := compareObjects(args[0], &object.Integer{Value: 0})
+				return &object.Integer{Value: int64(cmp)}
+			},
+		},
+		"gcd": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				a, b, err := integerPair("gcd", args)
+				if err != nil {
+					return err
+				}
+				divisor := gcd(a, b)
+				if divisor > math.MaxInt64 {
+					return newError("integer overflow: `gcd` of %d and %d", a, b)
+				}
+				return &object.Integer{Value: int64(divisor)}
+			},
+		},
+		"lcm": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				a, b, err := integerPair("lcm", args)
+				if err != nil {
+					return err
+				}
+				if a == 0 || b == 0 {
+					return &object.Integer{Value: 0}
+				}
+				// Work on magnitudes so that neither the multiply nor the
+				// final sign flip can wrap around.
+				x, y := absUint64(a)/gcd(a, b), absUint64(b)
+				if x > math.MaxInt64/y {
+					return newError("integer overflow: `lcm` of %d and %d", a, b)
+				}
+				return &object.Integer{Value: int64(x * y)}
+			},
+		},
+		"len": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				switch arg := args[0].(type) {
+				case *object.String:
+					// Counted in characters; byte_len gives the size in bytes.
+					return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+				case *object.Array:
+					return &object.Integer{Value: int64(len(arg.Elements))}
+				default:
+					return newError("argument to `len` not supported, got %s",
+						args[0].Type())
+				}
+			},
+		},
+		"update": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				if err := checkFunction("update", "second", args[1]); err != nil {
+					return err
+				}
+				switch coll := args[0].(type) {
+				case *object.Array:
+					elements := make([]object.Object, len(coll.Elements))
+					for i, el := range coll.Elements {
+						result := applyFunction(args[1], []object.Object{el}, env)
+						if isError(result) {
+							return result
+						}
+						elements[i] = result
+					}
+					return &object.Array{Elements: elements}
+				case *object.Hash:
+					updated := object.NewHash()
+					for _, hashKey := range coll.Order {
+						pair := coll.Pairs[hashKey]
+						result := applyFunction(args[1], []object.Object{pair.Value}, env)
+						if isError(result) {
+							return result
+						}
+						updated.Set(pair.Key.(object.Hashable), result)
+					}
+					return updated
+				default:
+					return newError("first argument to `update` must be HASH or ARRAY, got %s",
+						args[0].Type())
+				}
+			},
+		},
+		"str": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				if str, ok := args[0].(*object.String); ok {
+					return str
+				}
+				return &object.String{Value: args[0].Inspect()}
+			},
+		},
+		"repr": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				return &object.String{Value: repr(args[0])}
+			},
+		},
+		"group_by": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `group_by` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("group_by", "second", args[1]); err != nil {
+					return err
+				}
+				groups := object.NewHash()
+				for _, el := range array.Elements {
+					key := applyFunction(args[1], []object.Object{el}, env)
+					if isError(key) {
+						return key
+					}
+					hashable, ok := key.(object.Hashable)
+					if !ok {
+						return newError("unusable as hash key: %s", key.Type())
+					}
+					group := &object.Array{}
+					if pair, ok := groups.Pairs[hashable.HashKey()]; ok {
+						group = pair.Value.(*object.Array)
+					}
+					group.Elements = append(group.Elements, el)
+					groups.Set(hashable, group)
+				}
+				return groups
+			},
+		},
+		"zip_with": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3",
+						len(args))
+				}
+				left, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `zip_with` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				right, ok := args[1].(*object.Array)
+				if !ok {
+					return newError("second argument to `zip_with` must be ARRAY, got %s",
+						args[1].Type())
+				}
+				if err := checkFunction("zip_with", "third", args[2]); err != nil {
+					return err
+				}
+				length := len(left.Elements)
+				if len(right.Elements) < length {
+					length = len(right.Elements)
+				}
+				elements := make([]object.Object, length)
+				for i := range elements {
+					callArgs := []object.Object{left.Elements[i], right.Elements[i]}
+					result := applyFunction(args[2], callArgs, env)
+					if isError(result) {
+						return result
+					}
+					elements[i] = result
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"index_of": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `index_of` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				for i, el := range array.Elements {
+					if objectsEqual(el, args[1]) {
+						return &object.Integer{Value: int64(i)}
+					}
+				}
+				return &object.Integer{Value: -1}
+			},
+		},
+		"find": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `find` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("find", "second", args[1]); err != nil {
+					return err
+				}
+				for _, el := range array.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+					if isTruthy(result) {
+						return el
+					}
+				}
+				return NULL
+			},
+		},
+		"byte_len": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				str, ok := args[0].(*object.String)
+				if !ok {
+					return newError("argument to `byte_len` must be STRING, got %s",
+						args[0].Type())
+				}
+				return &object.Integer{Value: int64(len(str.Value))}
+			},
+		},
+		"dump": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				var out strings.Builder
+				dump(&out, args[0], 0)
+				out.WriteString("\n")
+				io.WriteString(env.Output(), out.String())
+				return NULL
+			},
+		},
+		"partition": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `partition` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("partition", "second", args[1]); err != nil {
+					return err
+				}
+				matches, rest := []object.Object{}, []object.Object{}
+				for _, el := range array.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+					switch result {
+					case TRUE:
+						matches = append(matches, el)
+					case FALSE:
+						rest = append(rest, el)
+					default:
+						return newError("predicate passed to `partition` must return BOOLEAN, got %s",
+							result.Type())
+					}
+				}
+				return &object.Array{Elements: []object.Object{
+					&object.Array{Elements: matches},
+					&object.Array{Elements: rest},
+				}}
+			},
+		},
+		"uniq": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("argument to `uniq` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				// Hashable elements are found through seen; the rest need a
+				// scan of everything kept so far.
+				seen := map[object.HashKey]bool{}
+				elements := []object.Object{}
+				for _, el := range array.Elements {
+					if key, ok := uniqKey(el); ok {
+						if !seen[key] {
+							seen[key] = true
+							elements = append(elements, el)
+						}
+						continue
+					}
+					duplicate := false
+					for _, kept := range elements {
+						if objectsEqual(kept, el) {
+							duplicate = true
+							break
+						}
+					}
+					if !duplicate {
+						elements = append(elements, el)
+					}
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"first": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				array, err := arrayArgument("first", args)
+				if err != nil {
+					return err
+				}
+				if len(array.Elements) == 0 {
+					return NULL
+				}
+				return array.Elements[0]
+			},
+		},
+		"last": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				array, err := arrayArgument("last", args)
+				if err != nil {
+					return err
+				}
+				if len(array.Elements) == 0 {
+					return NULL
+				}
+				return array.Elements[len(array.Elements)-1]
+			},
+		},
+		"rest": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				array, err := arrayArgument("rest", args)
+				if err != nil {
+					return err
+				}
+				if len(array.Elements) == 0 {
+					return NULL
+				}
+				elements := make([]object.Object, len(array.Elements)-1)
+				copy(elements, array.Elements[1:])
+				return &object.Array{Elements: elements}
+			},
+		},
+		"push": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `push` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				elements := make([]object.Object, len(array.Elements), len(array.Elements)+1)
+				copy(elements, array.Elements)
+				return &object.Array{Elements: append(elements, args[1])}
+			},
+		},
+		"puts": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				out := env.Output()
+				for _, arg := range args {
+					io.WriteString(out, arg.Inspect()+"\n")
+				}
+				return NULL
+			},
+		},
+		"exit": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) > 1 {
+					return newError("wrong number of arguments. got=%d, want=0 or 1",
+						len(args))
+				}
+				if len(args) == 0 {
+					return &object.Exit{}
+				}
+				code, ok := args[0].(*object.Integer)
+				if !ok {
+					return newError("argument to `exit` must be INTEGER, got %s",
+						args[0].Type())
+				}
+				return &object.Exit{Code: code.Value}
+			},
+		},
+		"chunk": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `chunk` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				size, ok := args[1].(*object.Integer)
+				if !ok {
+					return newError("second argument to `chunk` must be INTEGER, got %s",
+						args[1].Type())
+				}
+				if size.Value <= 0 {
+					return newError("chunk size must be positive, got %d", size.Value)
+				}
+				chunks := []object.Object{}
+				for start := 0; start < len(array.Elements); {
+					end := len(array.Elements)
+					if int64(end-start) > size.Value {
+						end = start + int(size.Value)
+					}
+					elements := make([]object.Object, end-start)
+					copy(elements, array.Elements[start:end])
+					chunks = append(chunks, &object.Array{Elements: elements})
+					start = end
+				}
+				return &object.Array{Elements: chunks}
+			},
+		},
+		"eq": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				return nativeBoolToBooleanObject(objectsEqual(args[0], args[1]))
+			},
+		},
+		"scan": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `scan` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("scan", "third", args[2]); err != nil {
+					return err
+				}
+				acc := args[1]
+				elements := []object.Object{acc}
+				for _, el := range array.Elements {
+					acc = applyFunction(args[2], []object.Object{acc, el}, env)
+					if isError(acc) {
+						return acc
+					}
+					elements = append(elements, acc)
+				}
+				return &object.Array{Elements: elements}
+			},
+		},
+		"clamp": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 3 {
+					return newError("wrong number of arguments. got=%d, want=3",
+						len(args))
+				}
+				for _, arg := range args {
+					if !isNumber(arg) {
+						return newError("arguments to `clamp` must be INTEGER or FLOAT, got %s",
+							arg.Type())
+					}
+				}
+				if order, _ := compareObjects(args[1], args[2]); order > 0 {
+					return newError("lower bound of `clamp` must not exceed upper bound, got %s > %s",
+						args[1].Inspect(), args[2].Inspect())
+				}
+				if order, _ := compareObjects(args[0], args[1]); order < 0 {
+					return args[1]
+				}
+				if order, _ := compareObjects(args[0], args[2]); order > 0 {
+					return args[2]
+				}
+				return args[0]
+			},
+		},
+		"to_pairs": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 1 {
+					return newError("wrong number of arguments. got=%d, want=1",
+						len(args))
+				}
+				hash, ok := args[0].(*object.Hash)
+				if !ok {
+					return newError("argument to `to_pairs` must be HASH, got %s",
+						args[0].Type())
+				}
+				pairs := make([]object.Object, 0, len(hash.Order))
+				for _, hashKey := range hash.Order {
+					pair := hash.Pairs[hashKey]
+					pairs = append(pairs, &object.Array{
+						Elements: []object.Object{pair.Key, pair.Value},
+					})
+				}
+				return &object.Array{Elements: pairs}
+			},
+		},
+		"from_pairs": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				pairs, err := arrayArgument("from_pairs", args)
+				if err != nil {
+					return err
+				}
+				hash := object.NewHash()
+				for i, el := range pairs.Elements {
+					pair, ok := el.(*object.Array)
+					if !ok || len(pair.Elements) != 2 {
+						return newError("element %d of `from_pairs` must be a [key, value] ARRAY, got %s",
+							i, el.Inspect())
+					}
+					key, ok := pair.Elements[0].(object.Hashable)
+					if !ok {
+						return newError("unusable as hash key: %s", pair.Elements[0].Type())
+					}
+					hash.Set(key, pair.Elements[1])
+				}
+				return hash
+			},
+		},
+		"count": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `count` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				count := 0
+				for _, el := range array.Elements {
+					if objectsEqual(el, args[1]) {
+						count++
+					}
+				}
+				return &object.Integer{Value: int64(count)}
+			},
+		},
+		"count_by": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `count_by` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("count_by", "second", args[1]); err != nil {
+					return err
+				}
+				count := 0
+				for _, el := range array.Elements {
+					result := applyFunction(args[1], []object.Object{el}, env)
+					if isError(result) {
+						return result
+					}
+					switch result {
+					case TRUE:
+						count++
+					case FALSE:
+					default:
+						return newError("predicate passed to `count_by` must return BOOLEAN, got %s",
+							result.Type())
+					}
+				}
+				return &object.Integer{Value: int64(count)}
+			},
+		},
+		"pick": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return selectKeys("pick", args, true)
+			},
+		},
+		"omit": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				return selectKeys("omit", args, false)
+			},
+		},
+		"sort_by": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				if len(args) != 2 {
+					return newError("wrong number of arguments. got=%d, want=2",
+						len(args))
+				}
+				array, ok := args[0].(*object.Array)
+				if !ok {
+					return newError("first argument to `sort_by` must be ARRAY, got %s",
+						args[0].Type())
+				}
+				if err := checkFunction("sort_by", "second", args[1]); err != nil {
+					return err
+				}
+				// Each key is computed once up front, then the elements are
+				// sorted by their keys; equal keys keep their original order.
+				keys := make([]object.Object, len(array.Elements))
+				for i, el := range array.Elements {
+					key := applyFunction(args[1], []object.Object{el}, env)
+					if isError(key) {
+						return key
+					}
+					if !isNumber(key) && key.Type() != object.STRING_OBJ {
+						return newError("key passed to `sort_by` must be INTEGER, FLOAT or STRING, got %s",
+							key.Type())
+					}
+					keys[i] = key
+				}
+				order := make([]int, len(keys))
+				for i := range order {
+					order[i] = i
+				}
+				var mismatch object.Object
+				sort.SliceStable(order, func(a, b int) bool {
+					cmp, ok := compareObjects(keys[order[a]], keys[order[b]])
+					if !ok && mismatch == nil {
+						mismatch = newError("cannot compare %s and %s",
+							keys[order[a]].Type(), keys[order[b]].Type())
+					}
+					return cmp < 0
+				})
+				if mismatch != nil {
+					return mismatch
+				}
+				sorted := make([]object.Object, len(order))
+				for i, index := range order {
+					sorted[i] = array.Elements[index]
+				}
+				return &object.Array{Elements: sorted}
+			},
+		},
+		"min_max": {
+			Fn: func(env *object.Environment, args ...object.Object) object.Object {
+				array, err := arrayArgument("min_max", args)
+				if err != nil {
+					return err
+				}
+				if len(array.Elements) == 0 {
+					return newError("`min_max` of empty array")
+				}
+				var lowest, highest object.Object
+				for _, el := range array.Elements {
+					if !isNumber(el) {
+						return newError("`min_max` requires an array of numbers, got %s",
+							el.Type())
+					}
+					if lowest == nil {
+						lowest, highest = el, el
+						continue
+					}
+					if cmp, _ := compareObjects(el, lowest); cmp < 0 {
+						lowest = el
+					}
+					if cmp, _ := compareObjects(el, highest); cmp > 0 {
+						highest = el
+					}
+				}
+				return &object.Array{Elements: []object.Object{lowest, highest}}
+			},
+		},
+	}
+}
+
+func flattenElements(elements []object.Object, depth int64) []object.Object {
+	flat := []object.Object{}
+	for _, el := range elements {
+		nested, ok := el.(*object.Array)
+		if !ok || depth == 0 {
+			flat = append(flat, el)
+			continue
+		}
+		flat = append(flat, flattenElements(nested.Elements, depth-1)...)
+	}
+	return flat
+}
+
+// extremeBy implements max_by (want=1) and min_by (want=-1): it returns the
+// first element whose key compares as want against every other key.
+func extremeBy(
+	name string,
+	want int,
+	env *object.Environment,
+	args []object.Object,
+) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("first argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+	if err := checkFunction(name, "second", args[1]); err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return newError("`%s` of empty array", name)
+	}
+	var best, bestKey object.Object
+	for _, el := range array.Elements {
+		key := applyFunction(args[1], []object.Object{el}, env)
+		if isError(key) {
+			return key
+		}
+		if best == nil {
+			best, bestKey = el, key
+			continue
+		}
+		cmp, ok := compareObjects(key, bestKey)
+		if !ok {
+			return newError("cannot compare %s and %s", key.Type(), bestKey.Type())
+		}
+		if cmp == want {
+			best, bestKey = el, key
+		}
+	}
+	return best
+}
+
+// reduceNumbers folds the numeric array in args with operator, starting
+// from initial; the result is an INTEGER unless a FLOAT is involved.
+func reduceNumbers(
+	name string,
+	args []object.Object,
+	initial int64,
+	operator string,
+) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+	var result object.Object = &object.Integer{Value: initial}
+	for _, el := range array.Elements {
+		if !isNumber(el) {
+			return newError("`%s` requires an array of numbers, got %s",
+				name, el.Type())
+		}
+		result = evalInfixExpression(operator, result, el)
+	}
+	return result
+}
+
+// checkFunction reports an error unless arg, the position argument to the
+// builtin name, can be called. Builtins check up front so that a wrong
+// argument is caught even when there is nothing to call it on.
+func checkFunction(name, position string, arg object.Object) *object.Error {
+	if t := arg.Type(); t != object.FUNCTION_OBJ && t != object.BUILTIN_OBJ {
+		return newError("%s argument to `%s` must be FUNCTION, got %s", position, name, t)
+	}
+	return nil
+}
+
+func integerPair(name string, args []object.Object) (int64, int64, *object.Error) {
+	if len(args) != 2 {
+		return 0, 0, newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	values := [2]int64{}
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return 0, 0, newError("arguments to `%s` must be INTEGER, got %s",
+				name, arg.Type())
+		}
+		values[i] = integer.Value
+	}
+	return values[0], values[1], nil
+}
+
+// gcd is Euclid's algorithm on absolute values; gcd(0, 0) is 0. It works in
+// uint64 because the absolute value of math.MinInt64 doesn't fit an int64,
+// so callers must check the result fits before converting it back.
+func gcd(a, b int64) uint64 {
+	x, y := absUint64(a), absUint64(b)
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return x
+}
+
+func absUint64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// repr is the debug form of obj: like Inspect, except that strings, including
+// those nested in arrays and hashes, are quoted and escaped.
+func repr(obj object.Object) string {
+	switch obj := obj.(type) {
+	case *object.String:
+		return ast.Quote(obj.Value)
+	case *object.Array:
+		elements := make([]string, len(obj.Elements))
+		for i, el := range obj.Elements {
+			elements[i] = repr(el)
+		}
+		return "[" + strings.Join(elements, ", ") + "]"
+	case *object.Hash:
+		pairs := make([]string, 0, len(obj.Order))
+		for _, hashKey := range obj.Order {
+			pair := obj.Pairs[hashKey]
+			pairs = append(pairs, repr(pair.Key)+": "+repr(pair.Value))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+	default:
+		return obj.Inspect()
+	}
+}
+
+// dump writes obj to out with one array element or hash pair per line,
+// indented two spaces per level of nesting. Leaves use the repr form.
+func dump(out *strings.Builder, obj object.Object, depth int) {
+	indent := strings.Repeat("  ", depth+1)
+	switch obj := obj.(type) {
+	case *object.Array:
+		if len(obj.Elements) == 0 {
+			out.WriteString("[]")
+			return
+		}
+		out.WriteString("[\n")
+		for i, el := range obj.Elements {
+			out.WriteString(indent)
+			dump(out, el, depth+1)
+			if i < len(obj.Elements)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent[2:] + "]")
+	case *object.Hash:
+		if len(obj.Order) == 0 {
+			out.WriteString("{}")
+			return
+		}
+		out.WriteString("{\n")
+		for i, hashKey := range obj.Order {
+			pair := obj.Pairs[hashKey]
+			out.WriteString(indent + repr(pair.Key) + ": ")
+			dump(out, pair.Value, depth+1)
+			if i < len(obj.Order)-1 {
+				out.WriteString(",")
+			}
+			out.WriteString("\n")
+		}
+		out.WriteString(indent[2:] + "}")
+	default:
+		out.WriteString(repr(obj))
+	}
+}
+
+// uniqKey returns the hash key uniq dedupes obj by. Integral floats share the
+// key of the equal integer, since objectsEqual treats 1 and 1.0 as equal.
+func uniqKey(obj object.Object) (object.HashKey, bool) {
+	if float, ok := obj.(*object.Float); ok {
+		if float.Value != math.Trunc(float.Value) || math.Abs(float.Value) >= 1<<63 {
+			return object.HashKey{}, false
+		}
+		obj = &object.Integer{Value: int64(float.Value)}
+	}
+	hashable, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, false
+	}
+	return hashable.HashKey(), true
+}
+
+// arrayArgument checks that args is a single array, for builtins such as
+// first that take nothing else.
+func arrayArgument(name string, args []object.Object) (*object.Array, *object.Error) {
+	if len(args) != 1 {
+		return nil, newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError("argument to `%s` must be ARRAY, got %s",
+			name, args[0].Type())
+	}
+	return array, nil
+}
+
+// selectKeys implements pick and omit: it copies the hash in args, keeping
+// the pairs whose keys are in the key array (keep) or those that aren't.
+// Pairs stay in the hash's insertion order.
+func selectKeys(name string, args []object.Object, keep bool) object.Object {
+	if len(args) != 2 {
+		return newError("wrong number of arguments. got=%d, want=2", len(args))
+	}
+	hash, ok := args[0].(*object.Hash)
+	if !ok {
+		return newError("first argument to `%s` must be HASH, got %s", name, args[0].Type())
+	}
+	keys, ok := args[1].(*object.Array)
+	if !ok {
+		return newError("second argument to `%s` must be ARRAY, got %s", name, args[1].Type())
+	}
+	listed := map[object.HashKey]bool{}
+	for _, key := range keys.Elements {
+		hashable, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		listed[hashable.HashKey()] = true
+	}
+	selected := object.NewHash()
+	for _, hashKey := range hash.Order {
+		if listed[hashKey] == keep {
+			pair := hash.Pairs[hashKey]
+			selected.Set(pair.Key.(object.Hashable), pair.Value)
+		}
+	}
+	return selected
+}
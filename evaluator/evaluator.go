@@ -1,11 +1,22 @@
 package evaluator
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"math"
 	"monkey_kd/ast"
 	"monkey_kd/object"
+	"monkey_kd/parser"
+	"strings"
+	"unicode/utf8"
 )
 
+// maxLength caps the bytes of a string and the elements of an array that a
+// single operation may build, so programs such as "a" * 9223372036854775807
+// get an error instead of exhausting memory.
+const maxLength = 1 << 24
+
 var (
 	NULL  = &object.Null{}
 	TRUE  = &object.Boolean{Value: true}
@@ -20,6 +31,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return Eval(node.Expression, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
@@ -29,6 +44,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
+		if node.Operator == "??" {
+			return evalCoalesceExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -42,18 +63,35 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
+	case *ast.WhileExpression:
+		return evalWhileExpression(node, env)
+	case *ast.WhileStatement:
+		result := evalWhileExpression(node.Loop, env)
+		if isError(result) || result.Type() == object.RETURN_VALUE_OBJ {
+			return result
+		}
+		return NULL
 	case *ast.ReturnStatement:
 		val := Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
+	case *ast.DeferStatement:
+		env.Defer(node.Expression)
 	case *ast.LetStatement:
+		// A let running again, as in a loop body, rebinds its own name.
+		name := node.Name.Value
+		if env.Options().StrictLet && env.Declared(name) && env.DeclaredBy(name) != node {
+			return newError("%s already declared", name)
+		}
 		val := Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		env.Declare(name, val, node)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.FunctionLiteral:
@@ -61,6 +99,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		body := node.Body
 		return &object.Function{Parameters: params, Env: env, Body: body}
 	case *ast.CallExpression:
+		if dot, ok := node.Function.(*ast.DotExpression); ok {
+			return evalMethodCall(dot, node.Arguments, env)
+		}
 		function := Eval(node.Function, env)
 		if isError(function) {
 			return function
@@ -69,31 +110,177 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return applyFunction(function, args, env)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.DotExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return evalDotExpression(left, node.Property.Value)
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
 	}
 	return nil
 }
 
 func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	result := evalProgramStatements(program, env)
+	if isExit(result) {
+		// exit ends the program on the spot; deferred calls are dropped
+		// rather than left to run with the env's next program.
+		env.TakeDeferred()
+		return result
+	}
+	if deferred := runDeferred(env); deferred != nil && !isError(result) {
+		return deferred
+	}
+	return result
+}
+
+func evalProgramStatements(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range program.Statements {
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue:
 			return result.Value
-		case *object.Error:
+		case *object.Error, *object.Exit:
 			return result
 		}
 	}
 	return result
 }
+
+// EvalAll evaluates program like Eval but returns the result of every
+// top-level statement, nil for statements such as let that have none. It
+// stops after a return or an error, which is the last result.
+func EvalAll(program *ast.Program, env *object.Environment) []object.Object {
+	results := []object.Object{}
+	EvalEach(program, env, func(i int, result object.Object) {
+		results = append(results, result)
+	})
+	return results
+}
+
+// EvalEach is EvalAll for callers that want each result as soon as its
+// statement has run, such as the REPL's step mode. visit is called with the
+// statement's index and result; an error from a deferred call is reported
+// last, with an index of len(program.Statements).
+func EvalEach(
+	program *ast.Program,
+	env *object.Environment,
+	visit func(i int, result object.Object),
+) {
+	for i, statement := range program.Statements {
+		result := Eval(statement, env)
+		visit(i, unwrapReturnValue(result))
+		if isExit(result) {
+			env.TakeDeferred()
+			return
+		}
+		if _, ok := result.(*object.ReturnValue); ok || isError(result) {
+			break
+		}
+	}
+	if deferred := runDeferred(env); deferred != nil {
+		visit(len(program.Statements), deferred)
+	}
+}
+
+// EvalSource parses src and evaluates it in env. It returns the parse errors
+// if src doesn't parse, in which case nothing is evaluated, and otherwise the
+// program's result: NULL for a program that ends without a value, such as a
+// lone let. It is the shared core of the embedding entry points.
+func EvalSource(src string, env *object.Environment) (object.Object, []string) {
+	program, parseErrors := parser.Parse(src)
+	if len(parseErrors) != 0 {
+		return nil, parseErrors
+	}
+	result := Eval(program, env)
+	if result == nil {
+		return NULL, nil
+	}
+	return result, nil
+}
+
+// EvalWith parses and evaluates src in a fresh environment seeded with vars,
+// for hosts using Monkey as an expression or rules language. Parse errors,
+// runtime errors and a call to exit, reported as "exit status N", are all
+// returned as the error.
+func EvalWith(src string, vars map[string]object.Object) (object.Object, error) {
+	env := object.NewEnvironment()
+	for name, val := range vars {
+		env.Set(name, val)
+	}
+	result, parseErrors := EvalSource(src, env)
+	if len(parseErrors) != 0 {
+		return nil, errors.New(strings.Join(parseErrors, "\n"))
+	}
+	switch result := result.(type) {
+	case *object.Error:
+		return nil, errors.New(result.Message)
+	case *object.Exit:
+		return nil, fmt.Errorf("exit status %d", result.Code)
+	}
+	return result, nil
+}
+
+// Result is everything Run learned from running a program.
+type Result struct {
+	// Value is the program's result: NULL when it ended without one, and
+	// nil if it didn't parse, stopped with an error or called exit.
+	Value object.Object
+	// Error is the runtime error the program stopped with, if any.
+	Error *object.Error
+	// ParseErrors lists the parse errors; the program isn't run if any.
+	ParseErrors []string
+	// Exited is set when the program called exit, with ExitCode its code.
+	Exited   bool
+	ExitCode int64
+	// Output is what the program printed through builtins such as puts.
+	Output string
+}
+
+// Run parses and evaluates src in a fresh environment, capturing its output
+// instead of writing it to stdout.
+func Run(src string) Result {
+	var output bytes.Buffer
+	env := object.NewEnvironment()
+	env.Options().Output = &output
+	value, parseErrors := EvalSource(src, env)
+	result := Result{Value: value, ParseErrors: parseErrors, Output: output.String()}
+	switch value := value.(type) {
+	case *object.Error:
+		result.Value, result.Error = nil, value
+	case *object.Exit:
+		result.Value, result.Exited, result.ExitCode = nil, true, value.Code
+	}
+	return result
+}
+
 func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 		if result != nil {
 			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			if rt == object.RETURN_VALUE_OBJ || isError(result) {
 				return result
 			}
 		}
@@ -125,6 +312,8 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "+":
+		return evalPlusPrefixOperatorExpression(right)
 	default:
 		return newError("unknown operator: %s%s", operator, right.Type())
 	}
@@ -144,11 +333,54 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator:-%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalPlusPrefixOperatorExpression(right object.Object) object.Object {
+	if !isNumber(right) {
+		return newError("unknown operator: +%s", right.Type())
+	}
+	return right
+}
+
+// evalLogicalExpression short-circuits && and ||: the right operand is only
+// evaluated when the left one doesn't already decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == "||") {
+		if env.Options().OperandLogic {
+			return left
+		}
+		return nativeBoolToBooleanObject(isTruthy(left))
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
+	right := Eval(node.Right, env)
+	if isError(right) || env.Options().OperandLogic {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalCoalesceExpression evaluates a ?? b: a unless it is null, in which
+// case b. b is only evaluated when needed.
+func evalCoalesceExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if left != nil && left != NULL {
+		return left
+	}
+	return Eval(node.Right, env)
 }
 
 func evalInfixExpression(
@@ -156,21 +388,91 @@ func evalInfixExpression(
 	left, right object.Object,
 ) object.Object {
 	switch {
+	case operator == "in":
+		return evalInExpression(left, right)
+	case operator == "..":
+		return evalRangeExpression(left, right)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && right.Type() == object.INTEGER_OBJ:
+		return repeatString(left.(*object.String), right.(*object.Integer))
+	case operator == "*" && left.Type() == object.INTEGER_OBJ && right.Type() == object.STRING_OBJ:
+		return repeatString(right.(*object.String), left.(*object.Integer))
+	case (operator == "==" || operator == "!=") && (left == NULL || right == NULL):
+		// null is what missing values evaluate to, so comparing anything
+		// with it is allowed; it is only equal to itself.
+		return nativeBoolToBooleanObject((left == right) == (operator == "=="))
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s",
+			left.Type(), operator, right.Type())
 	case operator == "==":
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
-	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s",
-			left.Type(), operator, right.Type())
 	default:
 		return newError("unknown operator: %s %s %s",
 			left.Type(), operator, right.Type())
 	}
 }
 
+// evalInExpression tests membership: an element of an array, compared
+// structurally, or a key of a hash.
+func evalInExpression(left, right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Array:
+		for _, el := range right.Elements {
+			if objectsEqual(el, left) {
+				return TRUE
+			}
+		}
+		return FALSE
+	case *object.Hash:
+		key, ok := left.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", left.Type())
+		}
+		_, ok = right.Pairs[key.HashKey()]
+		return nativeBoolToBooleanObject(ok)
+	default:
+		return newError("right operand of `in` must be ARRAY or HASH, got %s",
+			right.Type())
+	}
+}
+
+// evalRangeExpression builds the array for start..end. Both ends are
+// included, and the array counts down when end is below start.
+func evalRangeExpression(left, right object.Object) object.Object {
+	start, ok := left.(*object.Integer)
+	if !ok {
+		return newError("range bounds must be INTEGER, got %s", left.Type())
+	}
+	end, ok := right.(*object.Integer)
+	if !ok {
+		return newError("range bounds must be INTEGER, got %s", right.Type())
+	}
+	step, span := int64(1), uint64(end.Value)-uint64(start.Value)
+	if end.Value < start.Value {
+		step, span = -1, uint64(start.Value)-uint64(end.Value)
+	}
+	// The range holds span+1 elements, both bounds included.
+	if span >= maxLength {
+		return newError("range %d..%d exceeds %d elements", start.Value, end.Value, maxLength)
+	}
+	elements := make([]object.Object, 0, span+1)
+	for i := start.Value; ; i += step {
+		elements = append(elements, &object.Integer{Value: i})
+		// Checked after appending so an end of math.MaxInt64 can't overflow.
+		if i == end.Value {
+			break
+		}
+	}
+	return &object.Array{Elements: elements}
+}
+
 func evalIntegerInfixExpression(
 	operator string,
 	left, right object.Object,
@@ -184,7 +486,13 @@ func evalIntegerInfixExpression(
 		return &object.Integer{Value: leftVal - rightVal}
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
-	case "/":
+	case "/", "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if operator == "%" {
+			return &object.Integer{Value: leftVal % rightVal}
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -200,6 +508,129 @@ func evalIntegerInfixExpression(
 	}
 }
 
+func evalFloatInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+// toFloat widens an INTEGER or FLOAT object to a float64; callers must check
+// isNumber first.
+func toFloat(obj object.Object) float64 {
+	if integer, ok := obj.(*object.Integer); ok {
+		return float64(integer.Value)
+	}
+	return obj.(*object.Float).Value
+}
+
+func evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func repeatString(str *object.String, count *object.Integer) object.Object {
+	if count.Value < 0 {
+		return newError("negative repeat count: %d", count.Value)
+	}
+	// Dividing rather than multiplying keeps the check itself from
+	// overflowing.
+	if len(str.Value) > 0 && count.Value > int64(maxLength/len(str.Value)) {
+		return newError("repeated string too long: %d copies of %d bytes exceeds %d bytes",
+			count.Value, len(str.Value), maxLength)
+	}
+	return &object.String{Value: strings.Repeat(str.Value, int(count.Value))}
+}
+
+// evalAssignExpression rebinds an existing variable and returns the value
+// assigned. Compound operators such as += combine the current value with the
+// new one first.
+func evalAssignExpression(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	name := ae.Name.Value
+	current, declared := env.Get(name)
+	if !declared {
+		return newError("cannot assign to undeclared identifier: %s", name)
+	}
+	val := Eval(ae.Value, env)
+	if isError(val) {
+		return val
+	}
+	if ae.Operator != "=" {
+		// x += y is x = x + y, with x looked up before y is evaluated.
+		val = evalInfixExpression(strings.TrimSuffix(ae.Operator, "="), current, val)
+		if isError(val) {
+			return val
+		}
+	}
+	env.Assign(name, val)
+	return val
+}
+
+// evalWhileExpression runs the loop and returns the last body value, or
+// NULL if the body never ran. A return or an error stops the loop and is
+// passed on.
+func evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	var result object.Object = NULL
+	for {
+		condition := Eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return result
+		}
+		result = Eval(we.Body, env)
+		if result == nil {
+			result = NULL
+		}
+		if isError(result) || result.Type() == object.RETURN_VALUE_OBJ {
+			return result
+		}
+	}
+}
+
 func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
@@ -231,22 +662,31 @@ func newError(format string, a ...interface{}) *object.Error {
 	return &object.Error{Message: fmt.Sprintf(format, a...)}
 }
 
+// isError reports whether obj stops evaluation. An exit counts, so that it
+// unwinds through every caller exactly like an error does.
 func isError(obj object.Object) bool {
 	if obj != nil {
-		return obj.Type() == object.ERROR_OBJ
+		return obj.Type() == object.ERROR_OBJ || obj.Type() == object.EXIT_OBJ
 	}
 	return false
 }
 
+func isExit(obj object.Object) bool {
+	_, ok := obj.(*object.Exit)
+	return ok
+}
+
 func evalIdentifier(
 	node *ast.Identifier,
 	env *object.Environment,
 ) object.Object {
-	val, ok := env.Get(node.Value)
-	if !ok {
-		return newError("identifier not found: " + node.Value)
+	if val, ok := env.Get(node.Value); ok {
+		return val
 	}
-	return val
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: " + node.Value)
 }
 
 func evalExpressions(
@@ -264,14 +704,41 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
-	function, ok := fn.(*object.Function)
-	if !ok {
+func applyFunction(
+	fn object.Object,
+	args []object.Object,
+	env *object.Environment,
+) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d",
+				len(function.Parameters), len(args))
+		}
+		extendedEnv := extendFunctionEnv(function, args)
+		evaluated := Eval(function.Body, extendedEnv)
+		if isExit(evaluated) {
+			extendedEnv.TakeDeferred()
+			return evaluated
+		}
+		if deferred := runDeferred(extendedEnv); deferred != nil && !isError(evaluated) {
+			return deferred
+		}
+		return unwrapReturnValue(evaluated)
+	case *object.Builtin:
+		return function.Fn(env, args...)
+	default:
 		return newError("not a function: %s", fn.Type())
 	}
-	extendedEnv := extendFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
-	return unwrapReturnValue(evaluated)
+}
+
+// functionArity returns the number of parameters fn declares, or -1 when it
+// is a builtin or not callable.
+func functionArity(fn object.Object) int {
+	if function, ok := fn.(*object.Function); ok {
+		return len(function.Parameters)
+	}
+	return -1
 }
 
 func extendFunctionEnv(
@@ -285,9 +752,182 @@ func extendFunctionEnv(
 	return env
 }
 
+// runDeferred evaluates the expressions deferred in env, last registered
+// first. Every one of them runs; the first error, if any, is returned.
+func runDeferred(env *object.Environment) object.Object {
+	var firstError object.Object
+	for _, exp := range env.TakeDeferred() {
+		result := Eval(exp, env)
+		if isError(result) && firstError == nil {
+			firstError = result
+		}
+	}
+	return firstError
+}
+
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
 		return returnValue.Value
 	}
 	return obj
 }
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		return newError("index operator not supported: %s", left.Type())
+	}
+}
+
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements := array.(*object.Array).Elements
+	idx := index.(*object.Integer).Value
+	if idx < 0 || idx >= int64(len(elements)) {
+		return NULL
+	}
+	return elements[idx]
+}
+
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", index.Type())
+	}
+	pair, ok := hash.(*object.Hash).Pairs[key.HashKey()]
+	if !ok {
+		return NULL
+	}
+	return pair.Value
+}
+
+// lengthOf is the .length of obj: characters in a string, elements in an
+// array or entries in a hash.
+func lengthOf(obj object.Object) (*object.Integer, bool) {
+	switch obj := obj.(type) {
+	case *object.String:
+		return &object.Integer{Value: int64(utf8.RuneCountInString(obj.Value))}, true
+	case *object.Array:
+		return &object.Integer{Value: int64(len(obj.Elements))}, true
+	case *object.Hash:
+		return &object.Integer{Value: int64(len(obj.Pairs))}, true
+	default:
+		return nil, false
+	}
+}
+
+// evalDotExpression resolves obj.name, which on a hash is sugar for
+// obj["name"].
+func evalDotExpression(left object.Object, name string) object.Object {
+	// .length is checked first, so it wins even over a "length" hash key.
+	if name == "length" {
+		if length, ok := lengthOf(left); ok {
+			return length
+		}
+	}
+	if hash, ok := left.(*object.Hash); ok {
+		return evalHashIndexExpression(hash, &object.String{Value: name})
+	}
+	return newError("property access not supported: %s.%s", left.Type(), name)
+}
+
+func evalHashLiteral(
+	node *ast.HashLiteral,
+	env *object.Environment,
+) object.Object {
+	hash := object.NewHash()
+	for _, keyNode := range node.Keys {
+		key := Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+		value := Eval(node.Pairs[keyNode], env)
+		if isError(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return hash
+}
+
+// objectsEqual reports whether two objects are structurally equal: numbers
+// by value, strings by content, arrays element-wise and hashes pair-wise.
+// Anything else compares by identity.
+func objectsEqual(left, right object.Object) bool {
+	if isNumber(left) && isNumber(right) {
+		if left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ {
+			return left.(*object.Integer).Value == right.(*object.Integer).Value
+		}
+		return toFloat(left) == toFloat(right)
+	}
+	if left.Type() != right.Type() {
+		return false
+	}
+	switch left := left.(type) {
+	case *object.String:
+		return left.Value == right.(*object.String).Value
+	case *object.Array:
+		rightElements := right.(*object.Array).Elements
+		if len(left.Elements) != len(rightElements) {
+			return false
+		}
+		for i, el := range left.Elements {
+			if !objectsEqual(el, rightElements[i]) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		rightPairs := right.(*object.Hash).Pairs
+		if len(left.Pairs) != len(rightPairs) {
+			return false
+		}
+		for hashKey, pair := range left.Pairs {
+			other, ok := rightPairs[hashKey]
+			if !ok || !objectsEqual(pair.Value, other.Value) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+// compareObjects orders two numbers or two strings, returning -1, 0 or 1.
+// ok is false when the pair has no ordering.
+func compareObjects(left, right object.Object) (cmp int, ok bool) {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		leftVal := left.(*object.Integer).Value
+		rightVal := right.(*object.Integer).Value
+		return compareOrdered(leftVal < rightVal, leftVal > rightVal), true
+	case isNumber(left) && isNumber(right):
+		leftVal, rightVal := toFloat(left), toFloat(right)
+		return compareOrdered(leftVal < rightVal, leftVal > rightVal), true
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		leftVal := left.(*object.String).Value
+		rightVal := right.(*object.String).Value
+		return compareOrdered(leftVal < rightVal, leftVal > rightVal), true
+	default:
+		return 0, false
+	}
+}
+
+func compareOrdered(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,54 @@
+package evaluator
+
+import (
+	"monkey_kd/ast"
+	"monkey_kd/object"
+)
+
+type methodKey struct {
+	receiver object.ObjectType
+	name     string
+}
+
+// methods lets builtins be called method-style: receiver.name(args...) calls
+// the named builtin with the receiver prepended to args.
+var methods = map[methodKey]string{
+	{object.STRING_OBJ, "len"}:      "len",
+	{object.STRING_OBJ, "byte_len"}: "byte_len",
+	{object.ARRAY_OBJ, "len"}:       "len",
+}
+
+func lookupMethod(receiver object.Object, name string) (*object.Builtin, bool) {
+	builtinName, ok := methods[methodKey{receiver.Type(), name}]
+	if !ok {
+		return nil, false
+	}
+	return builtins[builtinName], true
+}
+
+// evalMethodCall evaluates receiver.name(args...). A hash entry called name
+// takes precedence over a method of the same name.
+func evalMethodCall(
+	dot *ast.DotExpression,
+	arguments []ast.Expression,
+	env *object.Environment,
+) object.Object {
+	receiver := Eval(dot.Left, env)
+	if isError(receiver) {
+		return receiver
+	}
+	function := evalDotExpression(receiver, dot.Property.Value)
+	args := evalExpressions(arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	if function == NULL || isError(function) {
+		if method, ok := lookupMethod(receiver, dot.Property.Value); ok {
+			return applyFunction(method, append([]object.Object{receiver}, args...), env)
+		}
+	}
+	if isError(function) {
+		return function
+	}
+	return applyFunction(function, args, env)
+}
@@ -0,0 +1,5 @@
+package evaluator
+
+// Version identifies this interpreter. It is what the `version` builtin
+// returns and what the REPL banner shows.
+const Version = "monkey_kd 0.1"
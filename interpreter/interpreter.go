@@ -0,0 +1,22 @@
+// Package interpreter runs Monkey source in one call, for Go programs that
+// embed the language without wiring the lexer, parser and evaluator
+// themselves.
+package interpreter
+
+import (
+	"monkey_kd/evaluator"
+	"monkey_kd/object"
+)
+
+// Run evaluates input in a fresh environment. It returns the program's
+// result, or the parse errors if input doesn't parse, in which case nothing
+// is evaluated. Runtime errors are returned as *object.Error results.
+func Run(input string) (object.Object, []string) {
+	return RunEnv(input, object.NewEnvironment())
+}
+
+// RunEnv is Run in an existing environment, so bindings made by one call are
+// visible to the next.
+func RunEnv(input string, env *object.Environment) (object.Object, []string) {
+	return evaluator.EvalSource(input, env)
+}
@@ -1,7 +1,11 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey_kd/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
@@ -9,15 +13,26 @@ type Lexer struct {
 	position     int
 	readPosition int
 	char         byte
+
+	// line is the current line number, and column the column of the
+	// character at columnOffset; columns are counted on from there so long
+	// lines aren't rescanned for every token.
+	line         int
+	column       int
+	columnOffset int
 }
 
 func New(input string) *Lexer {
-	lex := &Lexer{input: input}
+	lex := &Lexer{input: input, line: 1, column: 1}
 	lex.readChar()
 	return lex
 }
 
 func (lex *Lexer) readChar() {
+	if lex.char == '\n' {
+		lex.line++
+		lex.column, lex.columnOffset = 1, lex.readPosition
+	}
 	if lex.readPosition >= len(lex.input) {
 		lex.char = 0
 	} else {
@@ -28,9 +43,30 @@ func (lex *Lexer) readChar() {
 }
 
 func (lex *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	lex.skipWhitespace()
+	offset := lex.offset()
+	lex.column += utf8.RuneCountInString(lex.input[lex.columnOffset:offset])
+	lex.columnOffset = offset
+	line, column := lex.line, lex.column
+	tok := lex.readToken()
+	tok.Offset = offset
+	tok.End = lex.offset()
+	tok.Line = line
+	tok.Column = column
+	return tok
+}
+
+// offset is the byte offset of the current character, clamped to the end of
+// the input once EOF has been reached.
+func (lex *Lexer) offset() int {
+	if lex.position > len(lex.input) {
+		return len(lex.input)
+	}
+	return lex.position
+}
+
+func (lex *Lexer) readToken() token.Token {
+	var tok token.Token
 
 	switch lex.char {
 	case '=':
@@ -43,9 +79,9 @@ func (lex *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, lex.char)
 		}
 	case '+':
-		tok = newToken(token.PLUS, lex.char)
+		tok = lex.readOperator(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.MINUS, lex.char)
+		tok = lex.readOperator(token.MINUS, token.MINUS_ASSIGN)
 	case '!':
 		if lex.peekChar() == '=' {
 			char := lex.char
@@ -55,16 +91,58 @@ func (lex *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.BANG, lex.char)
 		}
+	case '&':
+		if lex.peekChar() == '&' {
+			lex.readChar()
+			tok = token.Token{Type: token.AND, Literal: "&&"}
+		} else {
+			tok = newToken(token.ILLEGAL, lex.char)
+		}
+	case '|':
+		if lex.peekChar() == '|' {
+			lex.readChar()
+			tok = token.Token{Type: token.OR, Literal: "||"}
+		} else {
+			tok = newToken(token.ILLEGAL, lex.char)
+		}
+	case '?':
+		if lex.peekChar() == '?' {
+			lex.readChar()
+			tok = token.Token{Type: token.COALESCE, Literal: "??"}
+		} else {
+			tok = newToken(token.ILLEGAL, lex.char)
+		}
 	case '/':
-		tok = newToken(token.SLASH, lex.char)
+		if lex.peekChar() == '*' {
+			// An unterminated block comment; it runs to the end of input.
+			tok = token.Token{Type: token.ILLEGAL, Literal: lex.input[lex.position:]}
+			for lex.readPosition < len(lex.input) {
+				lex.readChar()
+			}
+		} else {
+			tok = lex.readOperator(token.SLASH, token.SLASH_ASSIGN)
+		}
 	case '*':
-		tok = newToken(token.ASTERISK, lex.char)
+		tok = lex.readOperator(token.ASTERISK, token.ASTERISK_ASSIGN)
+	case '%':
+		tok = newToken(token.PERCENT, lex.char)
 	case '<':
 		tok = newToken(token.LT, lex.char)
 	case '>':
 		tok = newToken(token.GT, lex.char)
 	case ';':
 		tok = newToken(token.SEMICOLON, lex.char)
+	case '.':
+		// readNumber only takes a '.' followed by a digit, so 1..5 lexes
+		// as INT RANGE INT while 1.5 stays a FLOAT.
+		if lex.peekChar() == '.' {
+			lex.readChar()
+			tok = token.Token{Type: token.RANGE, Literal: ".."}
+		} else {
+			tok = newToken(token.DOT, lex.char)
+		}
+	case ':':
+		tok = newToken(token.COLON, lex.char)
 	case ',':
 		tok = newToken(token.COMMA, lex.char)
 	case '(':
@@ -75,6 +153,20 @@ func (lex *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, lex.char)
 	case '}':
 		tok = newToken(token.RBRACE, lex.char)
+	case '[':
+		tok = newToken(token.LBRACKET, lex.char)
+	case ']':
+		tok = newToken(token.RBRACKET, lex.char)
+	case '"':
+		start := lex.position
+		tok.Type = token.STRING
+		tok.Literal = lex.readString()
+		if lex.char != '"' {
+			// Unterminated: the ILLEGAL literal keeps the opening quote so
+			// it reads as the start of a string in error messages.
+			tok.Type = token.ILLEGAL
+			tok.Literal = lex.input[start:lex.offset()]
+		}
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -84,8 +176,7 @@ func (lex *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdentifier(tok.Literal)
 			return tok
 		} else if isDigit(lex.char) {
-			tok.Type = token.INT
-			tok.Literal = lex.readNumber()
+			tok.Literal, tok.Type = lex.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, lex.char)
@@ -100,6 +191,17 @@ func newToken(tokenType token.TokenType, char byte) token.Token {
 	return token.Token{Type: tokenType, Literal: string(char)}
 }
 
+// readOperator lexes an operator that has a compound assignment form, such
+// as + and +=.
+func (lex *Lexer) readOperator(plain, compound token.TokenType) token.Token {
+	if lex.peekChar() != '=' {
+		return newToken(plain, lex.char)
+	}
+	char := lex.char
+	lex.readChar()
+	return token.Token{Type: compound, Literal: string(char) + "="}
+}
+
 func (lex *Lexer) readIdentifier() string {
 	position := lex.position
 	for isLetter(lex.char) {
@@ -112,11 +214,47 @@ func isLetter(char byte) bool {
 	return 'a' <= char && char <= 'z' || 'A' <= char && char <= 'Z' || char == '_'
 }
 
-func (lex *Lexer) readNumber() string {
+// readNumber reads an integer or float literal. Integers may carry a 0x, 0o
+// or 0b prefix; everything alphanumeric after the prefix is taken into the
+// literal, so the parser can report 0xZZ as a whole.
+func (lex *Lexer) readNumber() (string, token.TokenType) {
 	position := lex.position
+	tokenType := token.TokenType(token.INT)
+	if lex.char == '0' && strings.ContainsRune("xXoObB", rune(lex.peekChar())) {
+		lex.readChar()
+		lex.readChar()
+		for isLetter(lex.char) || isDigit(lex.char) {
+			lex.readChar()
+		}
+		return lex.input[position:lex.position], tokenType
+	}
 	for isDigit(lex.char) {
 		lex.readChar()
 	}
+	if lex.char == '.' && isDigit(lex.peekChar()) {
+		tokenType = token.FLOAT
+		lex.readChar()
+		for isDigit(lex.char) {
+			lex.readChar()
+		}
+	}
+	return lex.input[position:lex.position], tokenType
+}
+
+// readString returns the raw text between the quotes. Escapes are left for
+// the parser to decode; the lexer only makes sure \" doesn't end the string.
+func (lex *Lexer) readString() string {
+	position := lex.position + 1
+	for {
+		lex.readChar()
+		if lex.char == '\\' && lex.peekChar() != 0 {
+			lex.readChar()
+			continue
+		}
+		if lex.char == '"' || lex.char == 0 {
+			break
+		}
+	}
 	return lex.input[position:lex.position]
 }
 
@@ -124,9 +262,37 @@ func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
 
+// skipWhitespace skips whitespace and comments: // to the end of the line
+// and /* */, which doesn't nest and ends at the first */. An unterminated
+// /* is left for readToken to report.
 func (lex *Lexer) skipWhitespace() {
-	for lex.char == ' ' || lex.char == '\t' || lex.char == '\n' || lex.char == '\r' {
-		lex.readChar()
+	for {
+		if lex.char == '/' && lex.peekChar() == '/' {
+			for lex.char != '\n' && lex.char != 0 {
+				lex.readChar()
+			}
+			continue
+		}
+		if lex.char == '/' && lex.peekChar() == '*' {
+			end := strings.Index(lex.input[lex.position+2:], "*/")
+			if end < 0 {
+				return
+			}
+			for stop := lex.position + 2 + end + 2; lex.position < stop; {
+				lex.readChar()
+			}
+			continue
+		}
+		char, size := rune(lex.char), 1
+		if lex.char >= utf8.RuneSelf {
+			char, size = utf8.DecodeRuneInString(lex.input[lex.position:])
+		}
+		if !unicode.IsSpace(char) {
+			return
+		}
+		for ; size > 0; size-- {
+			lex.readChar()
+		}
 	}
 }
 
@@ -137,3 +303,53 @@ func (lex *Lexer) peekChar() byte {
 		return lex.input[lex.readPosition]
 	}
 }
+
+// LexError describes an ILLEGAL token produced while lexing.
+type LexError struct {
+	Token   token.Token
+	Message string
+}
+
+func (err LexError) Error() string {
+	return err.Message
+}
+
+// Tokens lexes the rest of the input and returns every token, finishing
+// with the EOF token.
+func (lex *Lexer) Tokens() []token.Token {
+	tokens := []token.Token{}
+	for {
+		tok := lex.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// Tokenize lexes src to the end and returns every token, finishing with the
+// EOF token, along with an error for each ILLEGAL token encountered.
+func Tokenize(src string) ([]token.Token, []LexError) {
+	tokens := New(src).Tokens()
+	errors := []LexError{}
+	for _, tok := range tokens {
+		if tok.Type == token.ILLEGAL {
+			errors = append(errors, LexError{
+				Token:   tok,
+				Message: IllegalMessage(tok),
+			})
+		}
+	}
+	return tokens, errors
+}
+
+// IllegalMessage describes why the ILLEGAL token tok was rejected.
+func IllegalMessage(tok token.Token) string {
+	if strings.HasPrefix(tok.Literal, `"`) {
+		return "unterminated string literal"
+	}
+	if strings.HasPrefix(tok.Literal, "/*") {
+		return "unterminated block comment"
+	}
+	return fmt.Sprintf("illegal character %q", tok.Literal)
+}
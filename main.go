@@ -1,11 +1,30 @@
 package main
 import (
+	"errors"
 	"fmt"
 	"os"
+	"monkey_kd/evaluator"
 	"monkey_kd/repl"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		runFile(os.Args[1])
+		return
+	}
+	fmt.Printf("%s\n", evaluator.Version)
 	fmt.Printf("Insert commands:\n")
 	repl.Start(os.Stdin, os.Stdout)
+}
+
+func runFile(path string) {
+	err := repl.RunFile(path, os.Stdout)
+	var exit *repl.ExitError
+	if errors.As(err, &exit) {
+		os.Exit(int(exit.Code))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
\ No newline at end of file
@@ -1,28 +1,180 @@
 package object
 
+import (
+	"io"
+	"monkey_kd/ast"
+	"os"
+	"sync"
+)
+
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.options = outer.options
 	return env
 }
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, options: &Options{}}
+}
+
+// Options change how programs evaluated in an environment behave. They are
+// shared with every environment enclosed by it, so function bodies see the
+// same settings as the program that calls them.
+type Options struct {
+	// OperandLogic makes && and || return the operand that decided the
+	// result, as in JavaScript or Python, instead of true or false.
+	OperandLogic bool
+	// StrictLet makes a second let of the same name in the same scope an
+	// error. Shadowing a name from an enclosing scope is always allowed.
+	StrictLet bool
+	// Output is where builtins such as dump write; nil means os.Stdout.
+	Output io.Writer
+	// DigitSeparator, when set, is written between groups of three digits
+	// of integers displayed through Environment.Inspect, e.g. "," or "_".
+	DigitSeparator string
+}
+
+// NewSyncEnvironment returns an environment whose Get and Set are guarded by
+// a mutex, for hosts that share one environment across goroutines.
+// Environments enclosed by it stay lock-free.
+func NewSyncEnvironment() *Environment {
+	env := NewEnvironment()
+	env.mu = &sync.RWMutex{}
+	return env
 }
 
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	mu    *sync.RWMutex
+
+	options *Options
+
+	// declaredBy records the let statement that bound each name, so that
+	// StrictLet can let a loop body re-run its own lets.
+	declaredBy map[string]ast.Node
+
+	// deferred holds the expressions registered by `defer` in the call
+	// (or program) owning this environment, in registration order.
+	deferred []ast.Expression
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	if e.mu != nil {
+		e.mu.RLock()
+	}
 	obj, ok := e.store[name]
+	if e.mu != nil {
+		e.mu.RUnlock()
+	}
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
 	return obj, ok
 }
+
+// Declared reports whether name is bound in this environment itself,
+// ignoring enclosing ones.
+func (e *Environment) Declared(name string) bool {
+	if e.mu != nil {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	_, ok := e.store[name]
+	return ok
+}
+
+// DeclaredBy returns the let statement that bound name in this environment
+// itself through Declare, or nil.
+func (e *Environment) DeclaredBy(name string) ast.Node {
+	if e.mu != nil {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+	}
+	return e.declaredBy[name]
+}
+
+// Declare binds name like Set and remembers the let statement that did it.
+func (e *Environment) Declare(name string, val Object, by ast.Node) Object {
+	if e.mu != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if e.declaredBy == nil {
+		e.declaredBy = make(map[string]ast.Node)
+	}
+	e.store[name] = val
+	e.declaredBy[name] = by
+	return val
+}
+
 func (e *Environment) Set(name string, val Object) Object {
+	if e.mu != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
 	e.store[name] = val
 	return val
 }
+
+// Assign rebinds name in the innermost environment that declares it, and
+// reports false if none does.
+func (e *Environment) Assign(name string, val Object) bool {
+	if e.mu != nil {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer == nil {
+		return false
+	}
+	return e.outer.Assign(name, val)
+}
+
+// Options returns the environment's options; changing them affects every
+// environment sharing them.
+func (e *Environment) Options() *Options {
+	return e.options
+}
+
+// Output returns the writer builtins print to.
+func (e *Environment) Output() io.Writer {
+	if e.options.Output == nil {
+		return os.Stdout
+	}
+	return e.options.Output
+}
+
+// Inspect formats obj like obj.Inspect(), applying the display options,
+// for hosts such as the REPL that show values to people.
+func (e *Environment) Inspect(obj Object) string {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.InspectGrouped(e.options.DigitSeparator)
+	case *Array:
+		return obj.InspectWith(e.Inspect)
+	case *Hash:
+		return obj.InspectWith(e.Inspect)
+	default:
+		return obj.Inspect()
+	}
+}
+
+func (e *Environment) Defer(exp ast.Expression) {
+	e.deferred = append(e.deferred, exp)
+}
+
+// TakeDeferred returns the deferred expressions in the order they should run
+// (last registered first) and clears them.
+func (e *Environment) TakeDeferred() []ast.Expression {
+	deferred := make([]ast.Expression, 0, len(e.deferred))
+	for i := len(e.deferred) - 1; i >= 0; i-- {
+		deferred = append(deferred, e.deferred[i])
+	}
+	e.deferred = nil
+	return deferred
+}
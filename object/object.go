@@ -3,7 +3,9 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"monkey_kd/ast"
+	"strconv"
 	"strings"
 )
 
@@ -11,11 +13,17 @@ type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
+	EXIT_OBJ         = "EXIT"
 	FUNCTION_OBJ     = "FUNCTION"
+	STRING_OBJ       = "STRING"
+	BUILTIN_OBJ      = "BUILTIN"
+	HASH_OBJ         = "HASH"
+	ARRAY_OBJ        = "ARRAY"
 )
 
 type Object interface {
@@ -31,10 +39,50 @@ func (i *Integer) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+// InspectGrouped is Inspect with sep between each group of three digits,
+// such as 1,000,000 or 1_000_000. An empty sep gives plain digits.
+func (i *Integer) InspectGrouped(sep string) string {
+	digits := i.Inspect()
+	if sep == "" {
+		return digits
+	}
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	var out strings.Builder
+	for n, digit := range digits {
+		if n > 0 && (len(digits)-n)%3 == 0 {
+			out.WriteString(sep)
+		}
+		out.WriteRune(digit)
+	}
+	return sign + out.String()
+}
+
 func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect renders the shortest representation that round-trips, switching
+// to exponent form for very large or small magnitudes. Integral values keep
+// a trailing ".0" so they can't be mistaken for integers.
+func (f *Float) Inspect() string {
+	out := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(out, ".eIN") {
+		out += ".0"
+	}
+	return out
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -81,6 +129,20 @@ func (e *Error) Inspect() string {
 	return "ERROR: " + e.Message
 }
 
+// Exit is returned by the exit builtin. Like an error it unwinds every call
+// and ends the program; hosts read Code to set the process exit status.
+type Exit struct {
+	Code int64
+}
+
+func (e *Exit) Type() ObjectType {
+	return EXIT_OBJ
+}
+
+func (e *Exit) Inspect() string {
+	return fmt.Sprintf("exit(%d)", e.Code)
+}
+
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
@@ -105,3 +167,128 @@ func (f *Function) Inspect() string {
 	out.WriteString("\n}")
 	return out.String()
 }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+type BuiltinFunction func(env *Environment, args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	return a.InspectWith(Object.Inspect)
+}
+
+// InspectWith is Inspect with each element formatted by inspect.
+func (a *Array) InspectWith(inspect func(Object) string) string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, inspect(e))
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash keeps its pairs in insertion order: Order lists each key of Pairs
+// once, in the order it was first set.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Order []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair), Order: []HashKey{}}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Order = append(h.Order, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key.(Object), Value: value}
+}
+
+func (h *Hash) Inspect() string {
+	return h.InspectWith(Object.Inspect)
+}
+
+// InspectWith is Inspect with each key and value formatted by inspect.
+func (h *Hash) InspectWith(inspect func(Object) string) string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, hashKey := range h.Order {
+		pair := h.Pairs[hashKey]
+		pairs = append(pairs, fmt.Sprintf("%s: %s",
+			inspect(pair.Key), inspect(pair.Value)))
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
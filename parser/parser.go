@@ -1,43 +1,84 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"monkey_kd/ast"
 	"monkey_kd/lexer"
 	"monkey_kd/token"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN
+	COALESCE
+	OR
+	AND
 	EQUALS
 	LESSGREATER
+	RANGE
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
+// maxDepth bounds how deeply expressions may nest, so input such as
+// thousands of opening parens reports an error instead of exhausting the
+// stack.
+const maxDepth = 512
+
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.COALESCE:        COALESCE,
+	token.OR:              OR,
+	token.AND:             AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.IN:              LESSGREATER,
+	token.RANGE:           RANGE,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
 }
 
 type Parser struct {
+	// StopOnFirstError makes ParseProgram give up after the first error
+	// instead of recovering and reporting every error it finds.
+	StopOnFirstError bool
+	// WarnSemicolonInsertion records a warning wherever a statement is
+	// ended by a line break rather than a semicolon.
+	WarnSemicolonInsertion bool
+
 	lex            *lexer.Lexer
 	curToken       token.Token
 	peekToken      token.Token
 	errors         []string
+	warnings       []string
+	depth          int
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// leftErrors is the number of errors reported before the left operand
+	// handed to the current infix parse function began, so the function
+	// can tell whether that operand parsed cleanly.
+	leftErrors int
 }
 
 func New(lex *lexer.Lexer) *Parser {
@@ -50,13 +91,20 @@ func New(lex *lexer.Lexer) *Parser {
 	parse.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	parse.registerPrefix(token.IDENTIFIER, parse.parseIdentifier)
 	parse.registerPrefix(token.INT, parse.parseIntegerLiteral)
+	parse.registerPrefix(token.FLOAT, parse.parseFloatLiteral)
+	parse.registerPrefix(token.STRING, parse.parseStringLiteral)
+	parse.registerPrefix(token.ILLEGAL, parse.parseIllegal)
 	parse.registerPrefix(token.BANG, parse.parsePrefixExpression)
 	parse.registerPrefix(token.MINUS, parse.parsePrefixExpression)
+	parse.registerPrefix(token.PLUS, parse.parsePrefixExpression)
 	parse.registerPrefix(token.TRUE, parse.parseBoolean)
 	parse.registerPrefix(token.FALSE, parse.parseBoolean)
 	parse.registerPrefix(token.LPAREN, parse.parseGroupedExpression)
 	parse.registerPrefix(token.IF, parse.parseIfExpression)
+	parse.registerPrefix(token.WHILE, parse.parseWhileExpression)
 	parse.registerPrefix(token.FUNCTION, parse.parseFunctionLiteral)
+	parse.registerPrefix(token.LBRACE, parse.parseHashLiteral)
+	parse.registerPrefix(token.LBRACKET, parse.parseArrayLiteral)
 
 	// Infix
 	parse.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -64,11 +112,24 @@ func New(lex *lexer.Lexer) *Parser {
 	parse.registerInfix(token.MINUS, parse.parseInfixExpression)
 	parse.registerInfix(token.SLASH, parse.parseInfixExpression)
 	parse.registerInfix(token.ASTERISK, parse.parseInfixExpression)
+	parse.registerInfix(token.PERCENT, parse.parseInfixExpression)
+	parse.registerInfix(token.AND, parse.parseInfixExpression)
+	parse.registerInfix(token.OR, parse.parseInfixExpression)
+	parse.registerInfix(token.COALESCE, parse.parseInfixExpression)
 	parse.registerInfix(token.EQ, parse.parseInfixExpression)
 	parse.registerInfix(token.NOT_EQ, parse.parseInfixExpression)
 	parse.registerInfix(token.LT, parse.parseInfixExpression)
 	parse.registerInfix(token.GT, parse.parseInfixExpression)
+	parse.registerInfix(token.IN, parse.parseInfixExpression)
+	parse.registerInfix(token.RANGE, parse.parseInfixExpression)
+	parse.registerInfix(token.ASSIGN, parse.parseAssignExpression)
+	parse.registerInfix(token.PLUS_ASSIGN, parse.parseAssignExpression)
+	parse.registerInfix(token.MINUS_ASSIGN, parse.parseAssignExpression)
+	parse.registerInfix(token.ASTERISK_ASSIGN, parse.parseAssignExpression)
+	parse.registerInfix(token.SLASH_ASSIGN, parse.parseAssignExpression)
 	parse.registerInfix(token.LPAREN, parse.parseCallExpression)
+	parse.registerInfix(token.LBRACKET, parse.parseIndexExpression)
+	parse.registerInfix(token.DOT, parse.parseDotExpression)
 
 	// For setting current and peek token
 	parse.nextToken()
@@ -77,6 +138,14 @@ func New(lex *lexer.Lexer) *Parser {
 	return parse
 }
 
+// Parse parses src as a whole program and returns it together with any
+// parse errors. It never panics, whatever the input.
+func Parse(src string) (*ast.Program, []string) {
+	parse := New(lexer.New(src))
+	program := parse.ParseProgram()
+	return program, parse.Errors()
+}
+
 func (parse *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: parse.curToken, Value: parse.curToken.Literal}
 }
@@ -89,22 +158,61 @@ func (parse *Parser) nextToken() {
 func (parse *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
+	for _, span := range parse.ParseStatementSpans() {
+		program.Statements = append(program.Statements, span.Statement)
+	}
+	return program
+}
+
+// StatementSpan is a top-level statement together with the byte range
+// [Start, End) of the source it was parsed from.
+type StatementSpan struct {
+	Statement ast.Statement
+	Start     int
+	End       int
+}
+
+// ParseStatementSpans parses the whole input like ParseProgram, but also
+// reports where each top-level statement sits in the source so a host can
+// reparse only the statements an edit touches.
+func (parse *Parser) ParseStatementSpans() []StatementSpan {
+	spans := []StatementSpan{}
 	for parse.curToken.Type != token.EOF {
+		start := parse.curToken.Offset
 		stmt := parse.parseStatement()
+		if parse.StopOnFirstError && len(parse.errors) > 0 {
+			break
+		}
 		if stmt != nil {
-			program.Statements = append(program.Statements, stmt)
+			spans = append(spans, StatementSpan{
+				Statement: stmt,
+				Start:     start,
+				End:       parse.curToken.End,
+			})
 		}
 		parse.nextToken()
 	}
-	return program
+	return spans
 }
 
 func (parse *Parser) parseStatement() ast.Statement {
 	switch parse.curToken.Type {
 	case token.LET:
-		return parse.parseLetStatement()
+		// Checked here so a failed let is a nil Statement rather than a
+		// non-nil interface holding a nil *ast.LetStatement.
+		if stmt := parse.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	case token.RETURN:
 		return parse.parseReturnStatement()
+	case token.DEFER:
+		return parse.parseDeferStatement()
+	case token.WHILE:
+		if stmt := parse.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
 	default:
 		return parse.parseExpressionStatement()
 	}
@@ -121,9 +229,7 @@ func (parse *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	parse.nextToken()
 	stmt.Value = parse.parseExpression(LOWEST)
-	for !parse.curTokenIs(token.SEMICOLON) {
-		parse.nextToken()
-	}
+	parse.endStatement()
 	return stmt
 }
 
@@ -131,8 +237,26 @@ func (parse *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: parse.curToken}
 	parse.nextToken()
 	stmt.ReturnValue = parse.parseExpression(LOWEST)
+	parse.endStatement()
+	return stmt
+}
+
+func (parse *Parser) parseDeferStatement() *ast.DeferStatement {
+	stmt := &ast.DeferStatement{Token: parse.curToken}
+	parse.nextToken()
+	stmt.Expression = parse.parseExpression(LOWEST)
+	parse.endStatement()
+	return stmt
+}
 
-	for !parse.curTokenIs(token.SEMICOLON) {
+func (parse *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: parse.curToken}
+	loop, ok := parse.parseWhileExpression().(*ast.WhileExpression)
+	if !ok {
+		return nil
+	}
+	stmt.Loop = loop
+	if parse.peekTokenIs(token.SEMICOLON) {
 		parse.nextToken()
 	}
 	return stmt
@@ -158,10 +282,43 @@ func (parse *Parser) Errors() []string {
 	return parse.errors
 }
 
+// Warnings returns informational diagnostics that, unlike Errors, don't
+// stop the program from running.
+func (parse *Parser) Warnings() []string {
+	return parse.warnings
+}
+
+// endStatement consumes the semicolon ending the current statement, if there
+// is one. Otherwise, when the next token is on a later line, the line break
+// ended the statement, which is noted if WarnSemicolonInsertion is set.
+func (parse *Parser) endStatement() {
+	if parse.peekTokenIs(token.SEMICOLON) {
+		parse.nextToken()
+		return
+	}
+	if !parse.WarnSemicolonInsertion || parse.peekTokenIs(token.EOF) ||
+		parse.peekTokenIs(token.RBRACE) || parse.peekToken.Line <= parse.curToken.Line {
+		return
+	}
+	msg := fmt.Sprintf("%d:%d: semicolon inserted after %q",
+		parse.curToken.Line, parse.curToken.Column, parse.curToken.Literal)
+	parse.warnings = append(parse.warnings, msg)
+}
+
+// addError records msg, prefixed with the line and column of tok, the token
+// the error is about.
+func (parse *Parser) addError(tok token.Token, msg string) {
+	if parse.StopOnFirstError && len(parse.errors) > 0 {
+		return
+	}
+	msg = fmt.Sprintf("%d:%d: %s", tok.Line, tok.Column, msg)
+	parse.errors = append(parse.errors, msg)
+}
+
 func (parse *Parser) peekError(tok token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
 		tok, parse.peekToken.Type)
-	parse.errors = append(parse.errors, msg)
+	parse.addError(parse.peekToken, msg)
 }
 
 type (
@@ -179,24 +336,31 @@ func (parse *Parser) registerInfix(tokenType token.TokenType, fn infixParseFn) {
 func (parse *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: parse.curToken}
 	stmt.Expression = parse.parseExpression(LOWEST)
-	if parse.peekTokenIs(token.SEMICOLON) {
-		parse.nextToken()
-	}
+	parse.endStatement()
 	return stmt
 }
 
 func (parse *Parser) noPrefixParseFnError(tt token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found", tt)
-	parse.errors = append(parse.errors, msg)
+	parse.addError(parse.curToken, msg)
 }
 
 func (parse *Parser) parseExpression(precedence int) ast.Expression {
+	parse.depth++
+	defer func() { parse.depth-- }()
+	if parse.depth > maxDepth {
+		msg := fmt.Sprintf("expression nested too deeply (more than %d levels)", maxDepth)
+		parse.addError(parse.curToken, msg)
+		return nil
+	}
+
 	prefix := parse.prefixParseFns[parse.curToken.Type]
 	if prefix == nil {
 		parse.noPrefixParseFnError(parse.curToken.Type)
 		return nil
 	}
 
+	start := len(parse.errors)
 	leftExpression := prefix()
 
 	for !parse.peekTokenIs(token.SEMICOLON) && precedence < parse.peekPrecedence() {
@@ -205,23 +369,136 @@ func (parse *Parser) parseExpression(precedence int) ast.Expression {
 			return leftExpression
 		}
 		parse.nextToken()
+		parse.leftErrors = start
 		leftExpression = infix(leftExpression)
 	}
 	return leftExpression
 }
 
+// integerBases maps the prefixes of non-decimal integer literals, such as
+// the 0x of 0xFF, to their bases.
+var integerBases = map[string]int{
+	"0x": 16, "0X": 16,
+	"0o": 8, "0O": 8,
+	"0b": 2, "0B": 2,
+}
+
+// parseIntegerLiteral parses a decimal integer, or a hex, octal or binary one
+// written with a 0x, 0o or 0b prefix. A leading zero alone doesn't make it
+// octal: 010 is ten.
 func (parse *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: parse.curToken}
-	value, err := strconv.ParseInt(parse.curToken.Literal, 0, 64)
+	digits, base := parse.curToken.Literal, 10
+	if len(digits) >= 2 {
+		if prefixBase, ok := integerBases[digits[:2]]; ok {
+			digits, base = digits[2:], prefixBase
+		}
+	}
+	value, err := strconv.ParseInt(digits, base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// The literal is unsigned, so -9223372036854775808 is out of range
+		// as well; write it as -9223372036854775807 - 1 instead.
+		msg := fmt.Sprintf("integer literal %s out of range", parse.curToken.Literal)
+		parse.addError(parse.curToken, msg)
+		return nil
+	}
 	if err != nil {
 		msg := fmt.Sprintf("could not parse %q as integer", parse.curToken.Literal)
-		parse.errors = append(parse.errors, msg)
+		parse.addError(parse.curToken, msg)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (parse *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: parse.curToken}
+	value, err := strconv.ParseFloat(parse.curToken.Literal, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		// Reported rather than letting the literal silently become +Inf.
+		msg := fmt.Sprintf("float literal %s out of range", parse.curToken.Literal)
+		parse.addError(parse.curToken, msg)
+		return nil
+	}
+	if err != nil {
+		msg := fmt.Sprintf("could not parse %q as float", parse.curToken.Literal)
+		parse.addError(parse.curToken, msg)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+// parseIllegal reports a token the lexer rejected, such as a stray character
+// or an unterminated string, instead of the generic no-prefix error.
+func (parse *Parser) parseIllegal() ast.Expression {
+	parse.addError(parse.curToken, lexer.IllegalMessage(parse.curToken))
+	return nil
+}
+
+func (parse *Parser) parseStringLiteral() ast.Expression {
+	value, err := unescape(parse.curToken.Literal)
+	if err != nil {
+		parse.addError(parse.curToken, err.Error())
+		return nil
+	}
+	return &ast.StringLiteral{Token: parse.curToken, Value: value}
+}
+
+// unescape decodes the escapes in the raw text of a string literal: \n, \t,
+// \r, \", \\, \xHH for a single byte and \u{H...} for a Unicode code point.
+func unescape(raw string) (string, error) {
+	if !strings.Contains(raw, `\`) {
+		return raw, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] != '\\' {
+			out.WriteByte(raw[i])
+			continue
+		}
+		i++
+		if i == len(raw) {
+			return "", fmt.Errorf("unterminated escape sequence in string literal")
+		}
+		switch raw[i] {
+		case 'n':
+			out.WriteByte('\n')
+		case 't':
+			out.WriteByte('\t')
+		case 'r':
+			out.WriteByte('\r')
+		case '"', '\\':
+			out.WriteByte(raw[i])
+		case 'x':
+			if i+2 >= len(raw) {
+				return "", fmt.Errorf("invalid escape sequence %q in string literal", raw[i-1:])
+			}
+			value, err := strconv.ParseUint(raw[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence %q in string literal", raw[i-1:i+3])
+			}
+			out.WriteByte(byte(value))
+			i += 2
+		case 'u':
+			end := strings.IndexByte(raw[i:], '}')
+			if !strings.HasPrefix(raw[i:], "u{") || end < 0 {
+				return "", fmt.Errorf("invalid escape sequence %q in string literal", raw[i-1:i+1])
+			}
+			digits := raw[i+2 : i+end]
+			value, err := strconv.ParseUint(digits, 16, 32)
+			if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+				return "", fmt.Errorf("invalid escape sequence %q in string literal", raw[i-1:i+end+1])
+			}
+			out.WriteRune(rune(value))
+			i += end
+		default:
+			return "", fmt.Errorf("invalid escape sequence %q in string literal", raw[i-1:i+1])
+		}
+	}
+	return out.String(), nil
+}
+
 func (parse *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    parse.curToken,
@@ -258,6 +535,31 @@ func (parse *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseAssignExpression parses name = value and compound assignments such as
+// name += value. Assignment is right-associative, so a = b = 1 assigns 1 to
+// b and then to a.
+func (parse *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		// A left side that failed to parse may be missing children, so
+		// String() isn't safe on it; its error has been reported already.
+		if left == nil || len(parse.errors) != parse.leftErrors {
+			return nil
+		}
+		msg := fmt.Sprintf("cannot assign to %s", left.String())
+		parse.addError(parse.curToken, msg)
+		return nil
+	}
+	expression := &ast.AssignExpression{
+		Token:    parse.curToken,
+		Name:     name,
+		Operator: parse.curToken.Literal,
+	}
+	parse.nextToken()
+	expression.Value = parse.parseExpression(ASSIGN - 1)
+	return expression
+}
+
 func (parse *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{Token: parse.curToken, Value: parse.curTokenIs(token.TRUE)}
 }
@@ -287,14 +589,49 @@ func (parse *Parser) parseIfExpression() ast.Expression {
 	expression.Consequence = parse.parseBlockStatement()
 	if parse.peekTokenIs(token.ELSE) {
 		parse.nextToken()
-		if !parse.expectPeek(token.LBRACE) {
+		switch {
+		case parse.peekTokenIs(token.LBRACE):
+			parse.nextToken()
+			expression.Alternative = parse.parseBlockStatement()
+		case parse.peekTokenIs(token.IF):
+			// else if is sugar for an else block holding just the if.
+			parse.nextToken()
+			elseIf := &ast.ExpressionStatement{Token: parse.curToken}
+			elseIf.Expression = parse.parseIfExpression()
+			if elseIf.Expression == nil {
+				return nil
+			}
+			expression.Alternative = &ast.BlockStatement{
+				Token:      elseIf.Token,
+				Statements: []ast.Statement{elseIf},
+			}
+		default:
+			msg := fmt.Sprintf("expected { or if after else, got %s instead",
+				parse.peekToken.Type)
+			parse.addError(parse.peekToken, msg)
 			return nil
 		}
-		expression.Alternative = parse.parseBlockStatement()
 	}
 	return expression
 }
 
+func (parse *Parser) parseWhileExpression() ast.Expression {
+	expression := &ast.WhileExpression{Token: parse.curToken}
+	if !parse.expectPeek(token.LPAREN) {
+		return nil
+	}
+	parse.nextToken()
+	expression.Condition = parse.parseExpression(LOWEST)
+	if !parse.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !parse.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = parse.parseBlockStatement()
+	return expression
+}
+
 func (parse *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: parse.curToken}
 	if !parse.expectPeek(token.LPAREN) {
@@ -345,25 +682,80 @@ func (parse *Parser) parseFunctionParameters() []*ast.Identifier {
 
 func (parse *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: parse.curToken, Function: function}
-	exp.Arguments = parse.parseCallArguments()
+	exp.Arguments = parse.parseExpressionList(token.RPAREN)
 	return exp
 }
 
-func (parse *Parser) parseCallArguments() []ast.Expression {
-	args := []ast.Expression{}
-	if parse.peekTokenIs(token.RPAREN) {
+func (parse *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if parse.peekTokenIs(end) {
 		parse.nextToken()
-		return args
+		return list
 	}
 	parse.nextToken()
-	args = append(args, parse.parseExpression(LOWEST))
+	list = append(list, parse.parseExpression(LOWEST))
 	for parse.peekTokenIs(token.COMMA) {
 		parse.nextToken()
+		if parse.peekTokenIs(end) {
+			parse.nextToken()
+			msg := fmt.Sprintf("unexpected trailing comma before %s", end)
+			parse.addError(parse.curToken, msg)
+			return nil
+		}
 		parse.nextToken()
-		args = append(args, parse.parseExpression(LOWEST))
+		list = append(list, parse.parseExpression(LOWEST))
 	}
-	if !parse.expectPeek(token.RPAREN) {
+	if !parse.expectPeek(end) {
+		return nil
+	}
+	return list
+}
+
+func (parse *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: parse.curToken}
+	array.Elements = parse.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+func (parse *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: parse.curToken, Left: left}
+	parse.nextToken()
+	exp.Index = parse.parseExpression(LOWEST)
+	if !parse.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+func (parse *Parser) parseDotExpression(left ast.Expression) ast.Expression {
+	exp := &ast.DotExpression{Token: parse.curToken, Left: left}
+	if !parse.expectPeek(token.IDENTIFIER) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: parse.curToken, Value: parse.curToken.Literal}
+	return exp
+}
+
+func (parse *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: parse.curToken}
+	hash.Pairs = make(map[ast.Expression]ast.Expression)
+	hash.Keys = []ast.Expression{}
+	for !parse.peekTokenIs(token.RBRACE) {
+		parse.nextToken()
+		key := parse.parseExpression(LOWEST)
+		if !parse.expectPeek(token.COLON) {
+			return nil
+		}
+		parse.nextToken()
+		value := parse.parseExpression(LOWEST)
+		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
+		if !parse.peekTokenIs(token.RBRACE) && !parse.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !parse.expectPeek(token.RBRACE) {
 		return nil
 	}
-	return args
+	return hash
 }
@@ -0,0 +1,167 @@
+package repl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// command is a REPL directive entered as ":name args...". Commands are never
+// evaluated as Monkey source.
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(sess *session, args []string)
+}
+
+// commands is filled in by init because :help needs to range over it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:        "help",
+			usage:       ":help",
+			description: "list the available commands",
+			run:         runHelp,
+		},
+		{
+			name:        "check",
+			usage:       ":check on|off",
+			description: "only parse input and report errors, without evaluating",
+			run:         runCheck,
+		},
+		{
+			name:        "digits",
+			usage:       ":digits plain|comma|underscore",
+			description: "group the digits of large integers in results",
+			run:         runDigits,
+		},
+		{
+			name:        "step",
+			usage:       ":step on|off",
+			description: "evaluate one statement at a time, pausing for Enter",
+			run:         runStep,
+		},
+		{
+			name:        "ast",
+			usage:       ":ast on|off",
+			description: "print the parse tree of each input before evaluating it",
+			run:         runAST,
+		},
+		{
+			name:        "tokens",
+			usage:       ":tokens on|off",
+			description: "print the tokens of each input before evaluating it",
+			run:         runTokens,
+		},
+	}
+}
+
+// CommandNames returns the names of every registered REPL command.
+func CommandNames() []string {
+	names := []string{}
+	for _, cmd := range commands {
+		names = append(names, cmd.name)
+	}
+	return names
+}
+
+func lookupCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func (sess *session) runCommand(line string) {
+	fields := strings.Fields(strings.TrimPrefix(line, ":"))
+	if len(fields) == 0 {
+		fmt.Fprintf(sess.out, "unknown command %q, type :help for a list\n", line)
+		return
+	}
+	cmd, ok := lookupCommand(fields[0])
+	if !ok {
+		fmt.Fprintf(sess.out, "unknown command %q, type :help for a list\n", line)
+		return
+	}
+	cmd.run(sess, fields[1:])
+}
+
+// parseToggle reads the single on/off argument of a toggle command.
+func parseToggle(args []string) (value bool, ok bool) {
+	if len(args) != 1 {
+		return false, false
+	}
+	switch args[0] {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func runCheck(sess *session, args []string) {
+	value, ok := parseToggle(args)
+	if !ok {
+		fmt.Fprintln(sess.out, "usage: :check on|off")
+		return
+	}
+	sess.check = value
+}
+
+func runStep(sess *session, args []string) {
+	value, ok := parseToggle(args)
+	if !ok {
+		fmt.Fprintln(sess.out, "usage: :step on|off")
+		return
+	}
+	sess.step = value
+}
+
+func runTokens(sess *session, args []string) {
+	value, ok := parseToggle(args)
+	if !ok {
+		fmt.Fprintln(sess.out, "usage: :tokens on|off")
+		return
+	}
+	sess.tokens = value
+}
+
+func runAST(sess *session, args []string) {
+	value, ok := parseToggle(args)
+	if !ok {
+		fmt.Fprintln(sess.out, "usage: :ast on|off")
+		return
+	}
+	sess.ast = value
+}
+
+// digitSeparators maps the :digits arguments to separators.
+var digitSeparators = map[string]string{
+	"plain":      "",
+	"comma":      ",",
+	"underscore": "_",
+}
+
+func runDigits(sess *session, args []string) {
+	sep, ok := "", false
+	if len(args) == 1 {
+		sep, ok = digitSeparators[args[0]]
+	}
+	if !ok {
+		fmt.Fprintln(sess.out, "usage: :digits plain|comma|underscore")
+		return
+	}
+	sess.env.Options().DigitSeparator = sep
+}
+
+func runHelp(sess *session, args []string) {
+	for _, cmd := range commands {
+		fmt.Fprintf(sess.out, "  %-16s %s\n", cmd.usage, cmd.description)
+	}
+}
@@ -0,0 +1,55 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey_kd/evaluator"
+	"monkey_kd/object"
+	"monkey_kd/parser"
+	"os"
+)
+
+// ExitError is returned by RunFile when the script calls exit with a
+// non-zero code.
+type ExitError struct {
+	Code int64
+}
+
+func (err *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", err.Code)
+}
+
+// RunFile runs the Monkey script at path as one program, writing its output
+// to out. Parse errors are printed, each prefixed with its line and column,
+// and nothing is evaluated; otherwise the program's result is printed unless
+// it is null. RunFile returns an error if the file can't be read, doesn't
+// parse, or stops with a runtime error, and an *ExitError if it calls exit
+// with a non-zero code.
+func RunFile(path string, out io.Writer) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	program, errors := parser.Parse(string(src))
+	if len(errors) != 0 {
+		printParserErrors(out, errors)
+		return fmt.Errorf("%s: %d parse error(s)", path, len(errors))
+	}
+	env := object.NewEnvironment()
+	env.Options().Output = out
+	evaluated := evaluator.Eval(program, env)
+	switch evaluated := evaluated.(type) {
+	case *object.Exit:
+		if evaluated.Code != 0 {
+			return &ExitError{Code: evaluated.Code}
+		}
+		return nil
+	case *object.Error:
+		return fmt.Errorf("%s: %s", path, evaluated.Message)
+	}
+	if evaluated != nil && evaluated != evaluator.NULL {
+		io.WriteString(out, env.Inspect(evaluated))
+		io.WriteString(out, "\n")
+	}
+	return nil
+}
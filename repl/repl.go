@@ -4,44 +4,135 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey_kd/ast"
 	"monkey_kd/evaluator"
 	"monkey_kd/lexer"
 	"monkey_kd/object"
 	"monkey_kd/parser"
 	"monkey_kd/token"
+	"os"
+	"strings"
 )
 
 const PROMPT = ">> "
+const CONTINUATION_PROMPT = ".. "
+const STEP_PROMPT = "-- Enter to continue --"
+
+// session holds the state of one interactive REPL run.
+type session struct {
+	env     *object.Environment
+	out     io.Writer
+	scanner *bufio.Scanner
+
+	// interactive is set when input comes from a terminal; prompts are
+	// only printed then, so piped output stays clean.
+	interactive bool
+	// check reports parse errors (or "ok") instead of evaluating input.
+	check bool
+	// step evaluates one top-level statement at a time, waiting for a line
+	// of input between statements.
+	step bool
+	// tokens prints each input's tokens, as StartLexer does, before it is
+	// parsed.
+	tokens bool
+	// ast prints the parse tree of each input that parses.
+	ast bool
+}
+
+func (sess *session) prompt(prompt string) {
+	if sess.interactive {
+		fmt.Fprint(sess.out, prompt)
+	}
+}
+
+// isTerminal reports whether in is a character device such as a TTY.
+// Readers that aren't files, like pipes in tests, count as non-interactive.
+func isTerminal(in io.Reader) bool {
+	file, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
 
 func StartLexer(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	interactive := isTerminal(in)
 
 	for {
-		fmt.Printf(PROMPT)
+		if interactive {
+			fmt.Fprint(out, PROMPT)
+		}
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 
-		line := scanner.Text()
-		lex := lexer.New(line)
+		printTokens(out, scanner.Text())
+	}
+}
 
-		for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
-			fmt.Printf("%+v\n", tok)
-		}
+// printTokens writes each token of src on a line of its own.
+func printTokens(out io.Writer, src string) {
+	lex := lexer.New(src)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		fmt.Fprintf(out, "%+v\n", tok)
 	}
 }
 
 func Start(in io.Reader, out io.Writer) {
+	StartWithEnv(in, out, object.NewEnvironment())
+}
+
+// StartWithEnv runs the REPL in env, so hosts can seed variables or register
+// builtins before the first line is read.
+func StartWithEnv(in io.Reader, out io.Writer, env *object.Environment) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	sess := &session{
+		env:         env,
+		out:         out,
+		scanner:     scanner,
+		interactive: isTerminal(in),
+	}
+	// Builtins such as puts write to out too, so their output interleaves
+	// with the printed results, unless the host chose a writer already.
+	if env.Options().Output == nil {
+		env.Options().Output = out
+	}
 	for {
-		fmt.Printf(PROMPT)
+		sess.prompt(PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
 		}
 		line := scanner.Text()
+		for continuesLine(line) {
+			sess.prompt(CONTINUATION_PROMPT)
+			if !scanner.Scan() {
+				break
+			}
+			line = line[:len(line)-1] + scanner.Text()
+		}
+		if strings.HasPrefix(line, ":") {
+			sess.runCommand(line)
+			continue
+		}
+		// Keep reading while brackets are open. A blank line gives up on
+		// the rest, so a stray ( can't swallow everything typed after it.
+		for bracketDepth(line) > 0 {
+			sess.prompt(CONTINUATION_PROMPT)
+			if !scanner.Scan() || strings.TrimSpace(scanner.Text()) == "" {
+				break
+			}
+			line += "\n" + scanner.Text()
+		}
+		if sess.tokens {
+			printTokens(out, line)
+		}
 		lex := lexer.New(line)
 		parse := parser.New(lex)
 		program := parse.ParseProgram()
@@ -49,14 +140,80 @@ func Start(in io.Reader, out io.Writer) {
 			printParserErrors(out, parse.Errors())
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
+		if sess.ast {
+			io.WriteString(out, ast.Tree(program))
+		}
+		if sess.check {
+			io.WriteString(out, "ok\n")
+			continue
+		}
+		if sess.step {
+			if sess.stepProgram(program) {
+				return
+			}
+			continue
+		}
+		evaluated := evaluator.Eval(program, sess.env)
+		if _, ok := evaluated.(*object.Exit); ok {
+			return
+		}
 		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, sess.env.Inspect(evaluated))
 			io.WriteString(out, "\n")
 		}
 	}
 }
 
+// stepProgram evaluates program one top-level statement at a time, printing
+// each result and then waiting for a line of input before the next. It
+// reports whether the program called exit.
+func (sess *session) stepProgram(program *ast.Program) (exited bool) {
+	last := len(program.Statements) - 1
+	evaluator.EvalEach(program, sess.env, func(i int, result object.Object) {
+		if _, ok := result.(*object.Exit); ok {
+			exited = true
+			return
+		}
+		if result != nil {
+			io.WriteString(sess.out, sess.env.Inspect(result))
+			io.WriteString(sess.out, "\n")
+		}
+		if i >= last || isError(result) {
+			return
+		}
+		sess.prompt(STEP_PROMPT)
+		sess.scanner.Scan()
+	})
+	return exited
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// continuesLine reports whether line ends in a backslash that is not itself
+// escaped, meaning the next line should be joined onto it.
+func continuesLine(line string) bool {
+	trailing := len(line) - len(strings.TrimRight(line, "\\"))
+	return trailing%2 == 1
+}
+
+// bracketDepth returns how many (, { and [ in src are still unclosed, so the
+// REPL can keep reading lines until an expression is complete.
+func bracketDepth(src string) int {
+	depth := 0
+	lex := lexer.New(src)
+	for tok := lex.NextToken(); tok.Type != token.EOF; tok = lex.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+	}
+	return depth
+}
+
 func printParserErrors(out io.Writer, errors []string) {
 	for _, msg := range errors {
 		io.WriteString(out, "\t"+msg+"\n")
@@ -3,6 +3,7 @@ package test
 import (
 	"monkey_kd/token"
 	"monkey_kd/ast"
+	"monkey_kd/parser"
 	"testing"
 )
 
@@ -26,3 +27,74 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestTree(t *testing.T) {
+	input := `let f = fn(a, b) { if (a < b) { a } else { -b } };
+f(1 + 2 * 3, [x][0]).len;
+x += {"k": 1.5};
+while (true) { return "s" }`
+	expected := `Program
+  LetStatement f
+    FunctionLiteral (a, b)
+      body: BlockStatement
+        ExpressionStatement
+          IfExpression
+            condition: InfixExpression <
+              Identifier a
+              Identifier b
+            consequence: BlockStatement
+              ExpressionStatement
+                Identifier a
+            alternative: BlockStatement
+              ExpressionStatement
+                PrefixExpression -
+                  Identifier b
+  ExpressionStatement
+    DotExpression .len
+      CallExpression
+        function: Identifier f
+        argument: InfixExpression +
+          IntegerLiteral 1
+          InfixExpression *
+            IntegerLiteral 2
+            IntegerLiteral 3
+        argument: IndexExpression
+          ArrayLiteral
+            Identifier x
+          index: IntegerLiteral 0
+  ExpressionStatement
+    AssignExpression x +=
+      HashLiteral
+        key: StringLiteral "k"
+        value: FloatLiteral 1.5
+  WhileStatement
+    WhileExpression
+      condition: Boolean true
+      body: BlockStatement
+        ReturnStatement
+          StringLiteral "s"
+`
+	program, errors := parser.Parse(input)
+	if len(errors) != 0 {
+		t.Fatalf("parser has errors: %q", errors)
+	}
+	if got := ast.Tree(program); got != expected {
+		t.Errorf("ast.Tree wrong.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// unknownNode is a node type ast.Tree has no case for.
+type unknownNode struct{}
+
+func (unknownNode) TokenLiteral() string { return "?" }
+func (unknownNode) String() string { return "unknown" }
+
+func TestTreeUnknownNodes(t *testing.T) {
+	stmt := &ast.LetStatement{Name: &ast.Identifier{Value: "x"}}
+	if got := ast.Tree(stmt); got != "LetStatement x\n  <nil>\n" {
+		t.Errorf("missing value wrong. got=%q", got)
+	}
+	if got := ast.Tree(unknownNode{}); got != "test.unknownNode unknown\n" {
+		t.Errorf("unknown node wrong. got=%q", got)
+	}
+}
@@ -0,0 +1,782 @@
+package test
+
+import (
+	"bytes"
+	"monkey_kd/evaluator"
+	"monkey_kd/lexer"
+	"monkey_kd/object"
+	"monkey_kd/parser"
+	"strings"
+	"testing"
+)
+
+func testEvalEnv(input string, env *object.Environment) object.Object {
+	program := parser.New(lexer.New(input)).ParseProgram()
+	return evaluator.Eval(program, env)
+}
+
+// newRecordingEnvironment returns an environment with a `record` builtin
+// that appends the Inspect() of each argument to the returned slice.
+func newRecordingEnvironment() (*object.Environment, *[]string) {
+	seen := []string{}
+	env := object.NewEnvironment()
+	env.Set("record", &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			for _, arg := range args {
+				seen = append(seen, arg.Inspect())
+			}
+			return evaluator.NULL
+		},
+	})
+	return env, &seen
+}
+
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not Error. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q",
+			expected, errObj.Message)
+		return false
+	}
+	return true
+}
+
+func TestBuiltinOrdChar(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`ord("A")`, 65},
+		{`ord("é")`, 233},
+		{`char(65)`, "A"},
+		{`char(ord("Z"))`, "Z"},
+		{`ord(char(955))`, 955},
+		{`ord("")`, `argument to ` + "`ord`" + ` must be a single character, got ""`},
+		{`ord("AB")`, `argument to ` + "`ord`" + ` must be a single character, got "AB"`},
+		{`ord(1)`, "argument to `ord` must be STRING, got INTEGER"},
+		{`char(-1)`, "invalid code point passed to `char`: -1"},
+		{`char(55296)`, "invalid code point passed to `char`: 55296"},
+		{`char(1114112)`, "invalid code point passed to `char`: 1114112"},
+		{`char("A")`, "argument to `char` must be INTEGER, got STRING"},
+		{`char(1, 2)`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if _, ok := evaluated.(*object.Error); ok {
+				testErrorObject(t, evaluated, expected)
+			} else {
+				testStringObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestBuiltinMerge(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`merge({"a": 1}, {"b": 2})`, "{a: 1, b: 2}"},
+		{`merge({"a": 1, "b": 2}, {"b": 3, "c": 4})`, "{a: 1, b: 3, c: 4}"},
+		{`merge({}, {})`, "{}"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Hash); !ok {
+			t.Errorf("object is not Hash. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong merge result. expected=%q, got=%q",
+				tt.expected, evaluated.Inspect())
+		}
+	}
+	testErrorObject(t, testEval(`merge({}, 1)`),
+		"arguments to `merge` must be HASH, got INTEGER")
+	testErrorObject(t, testEval(`merge({})`),
+		"wrong number of arguments. got=1, want=2")
+
+	original := testEval(`let a = {"a": 1}; merge(a, {"a": 2}); a`)
+	if original.Inspect() != "{a: 1}" {
+		t.Errorf("merge mutated its argument. got=%q", original.Inspect())
+	}
+}
+
+func TestBuiltinRepeatStr(t *testing.T) {
+	testStringObject(t, testEval(`repeat_str("ab", 3)`), "ababab")
+	testStringObject(t, testEval(`repeat_str("x", 0)`), "")
+	testStringObject(t, testEval(`repeat_str("", 5)`), "")
+	testErrorObject(t, testEval(`repeat_str("x", -1)`), "negative repeat count: -1")
+	testErrorObject(t, testEval(`repeat_str("ab", 4611686018427387904)`),
+		"repeated string too long: 4611686018427387904 copies of 2 bytes exceeds 16777216 bytes")
+	testErrorObject(t, testEval(`repeat_str("x", 16777217)`),
+		"repeated string too long: 16777217 copies of 1 bytes exceeds 16777216 bytes")
+	testErrorObject(t, testEval(`repeat_str(1, 2)`),
+		"first argument to `repeat_str` must be STRING, got INTEGER")
+	testErrorObject(t, testEval(`repeat_str("x", "2")`),
+		"second argument to `repeat_str` must be INTEGER, got STRING")
+}
+
+func TestBuiltinAssertEq(t *testing.T) {
+	passing := []string{
+		`assert_eq(1 + 1, 2)`,
+		`assert_eq("a" + "b", "ab")`,
+		`assert_eq([1, [2, "x"]], [1, [2, "x"]])`,
+		`assert_eq({"a": [1], "b": {"c": true}}, {"b": {"c": true}, "a": [1]})`,
+		`assert_eq(2, 2.0)`,
+	}
+	for _, input := range passing {
+		testNullObject(t, testEval(input))
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`assert_eq([1, 2], [1, 3])`, "expected [1, 3], got [1, 2]"},
+		{`assert_eq({"a": 1}, {"a": 1, "b": 2})`, `expected {"a": 1, "b": 2}, got {"a": 1}`},
+		{`assert_eq(1, "1")`, `expected "1", got 1`},
+		{`assert_eq(["a b"], ["a", "b"])`, `expected ["a", "b"], got ["a b"]`},
+		{`assert_eq(1)`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBuiltinArray(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`array(5)`, "[null, null, null, null, null]"},
+		{`array(3, 0)`, "[0, 0, 0]"},
+		{`array(2, "x")`, "[x, x]"},
+		{`array(0)`, "[]"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if _, ok := evaluated.(*object.Array); !ok {
+			t.Errorf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %s. expected=%q, got=%q",
+				tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+	testErrorObject(t, testEval(`array(-1)`), "negative array length: -1")
+	testErrorObject(t, testEval(`array(9223372036854775807)`),
+		"array length 9223372036854775807 exceeds 16777216")
+	testErrorObject(t, testEval(`array(16777217, 0)`), "array length 16777217 exceeds 16777216")
+	testErrorObject(t, testEval(`array("3")`),
+		"first argument to `array` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`array()`),
+		"wrong number of arguments. got=0, want=1 or 2")
+}
+
+func TestBuiltinEach(t *testing.T) {
+	env, seen := newRecordingEnvironment()
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{`each([1, 2, 3], fn(x) { record(x * 10) })`, []string{"10", "20", "30"}},
+		{`each([], fn(x) { record(x) })`, nil},
+		{`each({"a": 1, "b": 2}, fn(v) { record(v) })`, []string{"1", "2"}},
+		{`each({"a": 1, "b": 2}, fn(k, v) { record(k, v) })`, []string{"a", "1", "b", "2"}},
+		{`each([4], record)`, []string{"4"}},
+	}
+	for _, tt := range tests {
+		*seen = nil
+		testNullObject(t, testEvalEnv(tt.input, env))
+		if strings.Join(*seen, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("callback calls wrong for %s. expected=%v, got=%v",
+				tt.input, tt.expected, *seen)
+		}
+	}
+	testErrorObject(t, testEval(`each([1], fn(x) { x + true })`),
+		"type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`each(1, fn(x) { x })`),
+		"first argument to `each` must be ARRAY or HASH, got INTEGER")
+}
+
+func TestBuiltinVersion(t *testing.T) {
+	testStringObject(t, testEval(`version()`), evaluator.Version)
+	testErrorObject(t, testEval(`version(1)`), "wrong number of arguments. got=1, want=0")
+}
+
+func TestBuiltinParseInt(t *testing.T) {
+	testIntegerObject(t, testEval(`parse_int("ff", 16)`), 255)
+	testIntegerObject(t, testEval(`parse_int("FF", 16)`), 255)
+	testIntegerObject(t, testEval(`parse_int("101", 2)`), 5)
+	testIntegerObject(t, testEval(`parse_int("-42", 10)`), -42)
+	testIntegerObject(t, testEval(`parse_int("z", 36)`), 35)
+	testErrorObject(t, testEval(`parse_int("102", 2)`), `could not parse "102" as base 2 integer`)
+	testErrorObject(t, testEval(`parse_int("", 10)`), `could not parse "" as base 10 integer`)
+	testErrorObject(t, testEval(`parse_int("1", 1)`), "base must be between 2 and 36, got 1")
+	testErrorObject(t, testEval(`parse_int("1", 37)`), "base must be between 2 and 36, got 37")
+	testErrorObject(t, testEval(`parse_int(1, 10)`),
+		"first argument to `parse_int` must be STRING, got INTEGER")
+}
+
+func testInspect(t *testing.T, input string, expected string) bool {
+	evaluated := testEval(input)
+	if errObj, ok := evaluated.(*object.Error); ok {
+		t.Errorf("%s returned error: %s", input, errObj.Message)
+		return false
+	}
+	if evaluated.Inspect() != expected {
+		t.Errorf("%s wrong. expected=%q, got=%q", input, expected, evaluated.Inspect())
+		return false
+	}
+	return true
+}
+
+func TestBuiltinRange(t *testing.T) {
+	testInspect(t, `range(4)`, "[0, 1, 2, 3]")
+	testInspect(t, `range(2, 5)`, "[2, 3, 4]")
+	testInspect(t, `range(5, 2)`, "[]")
+	testInspect(t, `range(0, 10, 2)`, "[0, 2, 4, 6, 8]")
+	testInspect(t, `range(0, 9, 3)`, "[0, 3, 6]")
+	testInspect(t, `range(10, 0, -2)`, "[10, 8, 6, 4, 2]")
+	testInspect(t, `range(0, 10, -1)`, "[]")
+	testInspect(t, `range(0, -5)`, "[]")
+	testInspect(t, `range(-3, 3, 4)`, "[-3, 1]")
+	// Steps that would carry i past the int64 limits stop at the last element.
+	testInspect(t, `range(9223372036854775800, 9223372036854775807, 5)`,
+		"[9223372036854775800, 9223372036854775805]")
+	testInspect(t, `range(-9223372036854775807 - 1 + 3, -9223372036854775807 - 1, -2)`,
+		"[-9223372036854775805, -9223372036854775807]")
+	testInspect(t, `range(-9223372036854775807 - 1, 9223372036854775807, 9223372036854775807)`,
+		"[-9223372036854775808, -1, 9223372036854775806]")
+	testErrorObject(t, testEval(`range(9223372036854775807)`),
+		"range of 9223372036854775807 elements exceeds 16777216")
+	testErrorObject(t, testEval(`range(-9223372036854775807 - 1, 9223372036854775807)`),
+		"range of 18446744073709551615 elements exceeds 16777216")
+	testErrorObject(t, testEval(`range(0, 10, 0)`), "range step must not be zero")
+	testErrorObject(t, testEval(`range("a")`), "arguments to `range` must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`range()`), "wrong number of arguments. got=0, want=1 to 3")
+}
+
+func TestBuiltinMaxByMinBy(t *testing.T) {
+	people := `let people = [{"name": "a", "score": 3}, {"name": "b", "score": 9}, {"name": "c", "score": 1}, {"name": "d", "score": 9}];`
+	testStringObject(t, testEval(people+`max_by(people, fn(x) { x.score }).name`), "b")
+	testStringObject(t, testEval(people+`min_by(people, fn(x) { x.score }).name`), "c")
+	testIntegerObject(t, testEval(`max_by([1, -5, 3], fn(x) { x * x })`), -5)
+	testIntegerObject(t, testEval(`min_by([2, 3], fn(x) { 0.5 * x })`), 2)
+	testStringObject(t, testEval(`max_by(["pear", "fig", "apple"], fn(x) { x })`), "pear")
+	testErrorObject(t, testEval(`max_by([], fn(x) { x })`), "`max_by` of empty array")
+	testErrorObject(t, testEval(`min_by([], fn(x) { x })`), "`min_by` of empty array")
+	testErrorObject(t, testEval(`max_by([1, "a"], fn(x) { x })`), "cannot compare STRING and INTEGER")
+	testErrorObject(t, testEval(`min_by(1, fn(x) { x })`),
+		"first argument to `min_by` must be ARRAY, got INTEGER")
+}
+
+func TestBuiltinFlatten(t *testing.T) {
+	testInspect(t, `flatten([[1, 2], [3, [4]]])`, "[1, 2, 3, [4]]")
+	testInspect(t, `flatten([[1, 2], [3, [4, [5]]]], -1)`, "[1, 2, 3, 4, 5]")
+	testInspect(t, `flatten([[1, [2, [3]]]], 2)`, "[1, 2, [3]]")
+	testInspect(t, `flatten([[1], 2], 0)`, "[[1], 2]")
+	testInspect(t, `flatten([])`, "[]")
+	testErrorObject(t, testEval(`flatten(1)`), "first argument to `flatten` must be ARRAY, got INTEGER")
+	testErrorObject(t, testEval(`flatten([], "1")`),
+		"second argument to `flatten` must be INTEGER, got STRING")
+}
+
+func TestBuiltinBetween(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`between(5, 1, 10)`, true},
+		{`between(1, 1, 10)`, true},
+		{`between(10, 1, 10)`, true},
+		{`between(0, 1, 10)`, false},
+		{`between(11, 1, 10)`, false},
+		{`between(0.5, 0, 1)`, true},
+		{`between(1.5, 0, 1)`, false},
+		{`between(-1, -2.5, -0.5)`, true},
+		{`between(5, 10, 1)`, false},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`between("a", 1, 2)`),
+		"arguments to `between` must be INTEGER or FLOAT, got STRING")
+	testErrorObject(t, testEval(`between(1, 2)`), "wrong number of arguments. got=2, want=3")
+}
+
+func TestBuiltinSumProductAvg(t *testing.T) {
+	testIntegerObject(t, testEval(`sum([1, 2, 3])`), 6)
+	testIntegerObject(t, testEval(`sum([])`), 0)
+	testFloatObject(t, testEval(`sum([1, 2.5])`), 3.5)
+	testIntegerObject(t, testEval(`product([2, 3, 4])`), 24)
+	testIntegerObject(t, testEval(`product([])`), 1)
+	testFloatObject(t, testEval(`product([2, 0.5])`), 1)
+	testIntegerObject(t, testEval(`avg([1, 2, 3])`), 2)
+	testFloatObject(t, testEval(`avg([1, 2])`), 1.5)
+	testFloatObject(t, testEval(`avg([1.0, 3.0])`), 2)
+	testErrorObject(t, testEval(`avg([])`), "`avg` of empty array")
+	testErrorObject(t, testEval(`sum([1, "2"])`), "`sum` requires an array of numbers, got STRING")
+	testErrorObject(t, testEval(`product(3)`), "argument to `product` must be ARRAY, got INTEGER")
+}
+
+func TestBuiltinNumberTheory(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`gcd(12, 18)`, 6},
+		{`gcd(-12, 18)`, 6},
+		{`gcd(7, 0)`, 7},
+		{`gcd(0, 7)`, 7},
+		{`gcd(0, 0)`, 0},
+		{`lcm(4, 6)`, 12},
+		{`lcm(-4, 6)`, 12},
+		{`lcm(0, 5)`, 0},
+		{`lcm(-4611686018427387904, 1)`, 4611686018427387904},
+		{`lcm(3074457345618258602, 3)`, 9223372036854775806},
+		{`sign(-7)`, -1},
+		{`sign(0)`, 0},
+		{`sign(42)`, 1},
+		{`sign(-0.5)`, -1},
+		{`abs(-7)`, 7},
+		{`abs(7)`, 7},
+		{`abs(-9223372036854775807)`, 9223372036854775807},
+		{`gcd(-9223372036854775807 - 1, 6)`, 2},
+		{`gcd(-9223372036854775807 - 1, 4611686018427387904)`, 4611686018427387904},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+	testFloatObject(t, testEval(`abs(-2.5)`), 2.5)
+	testErrorObject(t, testEval(`gcd(1.5, 2)`), "arguments to `gcd` must be INTEGER, got FLOAT")
+	testErrorObject(t, testEval(`lcm(1)`), "wrong number of arguments. got=1, want=2")
+	// The absolute value of the smallest integer doesn't fit in an integer.
+	testErrorObject(t, testEval(`abs(-9223372036854775807 - 1)`),
+		"integer overflow: `abs` of -9223372036854775808")
+	testErrorObject(t, testEval(`gcd(-9223372036854775807 - 1, 0)`),
+		"integer overflow: `gcd` of -9223372036854775808 and 0")
+	testErrorObject(t, testEval(`lcm(-9223372036854775807 - 1, -9223372036854775807 - 1)`),
+		"integer overflow: `lcm` of -9223372036854775808 and -9223372036854775808")
+	testErrorObject(t, testEval(`lcm(-9223372036854775807 - 1, 1)`),
+		"integer overflow: `lcm` of -9223372036854775808 and 1")
+	testErrorObject(t, testEval(`lcm(4611686018427387904, 3)`),
+		"integer overflow: `lcm` of 4611686018427387904 and 3")
+	testErrorObject(t, testEval(`sign("x")`), "argument to `sign` must be INTEGER or FLOAT, got STRING")
+}
+
+func TestBuiltinLen(t *testing.T) {
+	testIntegerObject(t, testEval(`len("")`), 0)
+	testIntegerObject(t, testEval(`len("four")`), 4)
+	testIntegerObject(t, testEval(`len([1, 2, 3])`), 3)
+	testIntegerObject(t, testEval(`len(["é"])`), 1)
+	testIntegerObject(t, testEval(`len("hello")`), 5)
+	testIntegerObject(t, testEval(`let xs = [1, 2, 3]; len(xs)`), 3)
+	testIntegerObject(t, testEval(`let size = len; size("ab")`), 2)
+	testIntegerObject(t, testEval(`let len = fn(x) { 99 }; len("ab")`), 99)
+	testErrorObject(t, testEval(`len(1)`), "argument to `len` not supported, got INTEGER")
+	testErrorObject(t, testEval(`len("one", "two")`), "wrong number of arguments. got=2, want=1")
+	testErrorObject(t, testEval(`len()`), "wrong number of arguments. got=0, want=1")
+}
+
+func TestBuiltinLenRunesAndBytes(t *testing.T) {
+	testIntegerObject(t, testEval(`len("é")`), 1)
+	testIntegerObject(t, testEval(`byte_len("é")`), 2)
+	testIntegerObject(t, testEval(`len("héllo, 世界")`), 9)
+	testIntegerObject(t, testEval(`byte_len("héllo, 世界")`), 14)
+	testIntegerObject(t, testEval(`"é".byte_len()`), 2)
+	testIntegerObject(t, testEval(`byte_len("")`), 0)
+	testErrorObject(t, testEval(`byte_len([1])`),
+		"argument to `byte_len` must be STRING, got ARRAY")
+}
+
+func TestBuiltinUpdate(t *testing.T) {
+	testInspect(t, `update({"a": 1, "b": 2}, fn(v) { v + 1 })`, "{a: 2, b: 3}")
+	testInspect(t, `let h = {"a": 1}; update(h, fn(v) { v * 10 }); h`, "{a: 1}")
+	testInspect(t, `update([1, 2, 3], fn(x) { x * x })`, "[1, 4, 9]")
+	testInspect(t, `update({}, fn(v) { v })`, "{}")
+	testErrorObject(t, testEval(`update({"a": 1, "b": true}, fn(v) { v + 1 })`),
+		"type mismatch: BOOLEAN + INTEGER")
+	testErrorObject(t, testEval(`update(1, fn(v) { v })`),
+		"first argument to `update` must be HASH or ARRAY, got INTEGER")
+	testErrorObject(t, testEval(`update({"a": 1}, 5)`),
+		"second argument to `update` must be FUNCTION, got INTEGER")
+}
+
+func TestBuiltinStrAndRepr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`str("a")`, `a`},
+		{`repr("a")`, `"a"`},
+		{`str(1.5)`, `1.5`},
+		{`repr(1.5)`, `1.5`},
+		{`str(true)`, `true`},
+		{`repr(["a", 1])`, `["a", 1]`},
+		{`str(["a", 1])`, `[a, 1]`},
+		{`repr({"k": "v"})`, `{"k": "v"}`},
+		{`repr("say \"hi\"\n")`, `"say \"hi\"\n"`},
+		{`str("say \"hi\"")`, `say "hi"`},
+	}
+	for _, tt := range tests {
+		testStringObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`repr()`), "wrong number of arguments. got=0, want=1")
+}
+
+func TestBuiltinGroupBy(t *testing.T) {
+	testInspect(t, `group_by([1, 2, 3, 4, 5], fn(x) { x - x / 2 * 2 })`,
+		"{1: [1, 3, 5], 0: [2, 4]}")
+	testInspect(t, `group_by(["bb", "a", "cc"], fn(s) { len(s) })`, "{2: [bb, cc], 1: [a]}")
+	testInspect(t, `group_by([], fn(x) { x })`, "{}")
+	testErrorObject(t, testEval(`group_by([1], fn(x) { [x] })`), "unusable as hash key: ARRAY")
+	testErrorObject(t, testEval(`group_by(1, fn(x) { x })`),
+		"first argument to `group_by` must be ARRAY, got INTEGER")
+	testErrorObject(t, testEval(`group_by([1], 2)`),
+		"second argument to `group_by` must be FUNCTION, got INTEGER")
+}
+
+func TestBuiltinZipWith(t *testing.T) {
+	testInspect(t, `zip_with([1, 2], [3, 4], fn(a, b) { a + b })`, "[4, 6]")
+	testInspect(t, `zip_with([1, 2, 3], [10], fn(a, b) { a * b })`, "[10]")
+	testInspect(t, `zip_with([], [1, 2], fn(a, b) { a })`, "[]")
+	testErrorObject(t, testEval(`zip_with([1], [true], fn(a, b) { a + b })`),
+		"type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval(`zip_with([1], [2], fn(a) { a })`),
+		"wrong number of arguments: want=1, got=2")
+	testErrorObject(t, testEval(`zip_with([1], 2, fn(a, b) { a })`),
+		"second argument to `zip_with` must be ARRAY, got INTEGER")
+}
+
+func TestBuiltinIndexOfAndFind(t *testing.T) {
+	testIntegerObject(t, testEval(`index_of([10, 20, 30], 20)`), 1)
+	testIntegerObject(t, testEval(`index_of([10, 20, 30], 40)`), -1)
+	testIntegerObject(t, testEval(`index_of([1, [2, 3], "a"], [2, 3])`), 1)
+	testIntegerObject(t, testEval(`index_of([1.0, 2], 1)`), 0)
+	testErrorObject(t, testEval(`index_of("abc", "b")`),
+		"first argument to `index_of` must be ARRAY, got STRING")
+
+	testIntegerObject(t, testEval(`find([1, 5, 8, 10], fn(x) { x > 4 })`), 5)
+	testNullObject(t, testEval(`find([1, 2, 3], fn(x) { x > 4 })`))
+	testNullObject(t, testEval(`find([], fn(x) { true })`))
+	testErrorObject(t, testEval(`find({}, fn(x) { true })`),
+		"first argument to `find` must be ARRAY, got HASH")
+	testErrorObject(t, testEval(`find([1], 1)`),
+		"second argument to `find` must be FUNCTION, got INTEGER")
+}
+
+func TestBuiltinDump(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Options().Output = &out
+	evaluated := testEvalEnv(`dump({"name": "monkey", "tags": [1, [], {}], "nested": {"ok": true}})`, env)
+	testNullObject(t, evaluated)
+	expected := `{
+  "name": "monkey",
+  "tags": [
+    1,
+    [],
+    {}
+  ],
+  "nested": {
+    "ok": true
+  }
+}
+`
+	if out.String() != expected {
+		t.Errorf("wrong dump output. expected=\n%s\ngot=\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	testEvalEnv(`dump(5)`, env)
+	if out.String() != "5\n" {
+		t.Errorf("wrong dump output. got=%q", out.String())
+	}
+}
+
+func TestBuiltinPartition(t *testing.T) {
+	testInspect(t, `partition([1, 2, 3, 4], fn(x) { x % 2 == 0 })`, "[[2, 4], [1, 3]]")
+	testInspect(t, `partition([2, 4], fn(x) { x % 2 == 0 })`, "[[2, 4], []]")
+	testInspect(t, `partition([], fn(x) { true })`, "[[], []]")
+	testErrorObject(t, testEval(`partition([1], fn(x) { x })`),
+		"predicate passed to `partition` must return BOOLEAN, got INTEGER")
+	testErrorObject(t, testEval(`partition("ab", fn(x) { true })`),
+		"first argument to `partition` must be ARRAY, got STRING")
+}
+
+func TestBuiltinUniq(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`uniq([1, 1, 2, 3, 3])`, "[1, 2, 3]"},
+		{`uniq([3, 1, 2])`, "[3, 1, 2]"},
+		{`uniq([])`, "[]"},
+		{`uniq(["a", "b", "a", true, true])`, "[a, b, true]"},
+		{`uniq([[1, 2], [1, 2], [2, 1], {"a": 1}, {"a": 1}])`, "[[1, 2], [2, 1], {a: 1}]"},
+		{`uniq([1, 1.0, 2.5, 2.5, 1])`, "[1, 2.5]"},
+		{`uniq([1.0, 1])`, "[1.0]"},
+	}
+	for _, tt := range tests {
+		testInspect(t, tt.input, tt.expected)
+	}
+	testErrorObject(t, testEval(`uniq("aab")`), "argument to `uniq` must be ARRAY, got STRING")
+}
+
+func TestBuiltinArrayAccessors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`rest([1, 2, 3])`, "[2, 3]"},
+		{`rest([1])`, "[]"},
+		{`rest([])`, nil},
+		{`push([1, 2], 3)`, "[1, 2, 3]"},
+		{`push([], [1])`, "[[1]]"},
+		{`let a = [1, 2]; let b = push(a, 3); [a, b]`, "[[1, 2], [1, 2, 3]]"},
+		{`let a = [1, 2, 3]; let r = rest(a); [a, r]`, "[[1, 2, 3], [2, 3]]"},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last("abc")`, "argument to `last` must be ARRAY, got STRING"},
+		{`rest([1], [2])`, "wrong number of arguments. got=2, want=1"},
+		{`push(1, 1)`, "first argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				testErrorObject(t, errObj, expected)
+			} else {
+				testInspect(t, tt.input, expected)
+			}
+		}
+	}
+}
+
+func TestBuiltinPuts(t *testing.T) {
+	var out bytes.Buffer
+	env := object.NewEnvironment()
+	env.Options().Output = &out
+	evaluated := testEvalEnv(`puts("hello", 1, [1, "a"]); puts(); let f = fn() { puts(true) }; f()`, env)
+	testNullObject(t, evaluated)
+	expected := "hello\n1\n[1, a]\ntrue\n"
+	if out.String() != expected {
+		t.Errorf("wrong puts output. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestBuiltinChunk(t *testing.T) {
+	testInspect(t, `chunk([1, 2, 3, 4], 2)`, "[[1, 2], [3, 4]]")
+	testInspect(t, `chunk([1, 2, 3, 4, 5], 2)`, "[[1, 2], [3, 4], [5]]")
+	testInspect(t, `chunk([1, 2], 5)`, "[[1, 2]]")
+	testInspect(t, `chunk([], 3)`, "[]")
+	testErrorObject(t, testEval(`chunk([1, 2], 0)`), "chunk size must be positive, got 0")
+	testErrorObject(t, testEval(`chunk([1, 2], -1)`), "chunk size must be positive, got -1")
+	testErrorObject(t, testEval(`chunk([1, 2], "2")`),
+		"second argument to `chunk` must be INTEGER, got STRING")
+}
+
+func TestBuiltinEq(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`eq([1, [2, {"a": [3]}]], [1, [2, {"a": [3]}]])`, true},
+		{`eq([1, [2, {"a": [3]}]], [1, [2, {"a": [4]}]])`, false},
+		{`eq({"a": 1, "b": 2}, {"b": 2, "a": 1})`, true},
+		{`eq([1, 2], [1, 2, 3])`, false},
+		{`eq("a", "a")`, true},
+		{`eq(1, 1.0)`, true},
+		{`eq(1, "1")`, false},
+		{`eq([], {})`, false},
+		{`eq(true, 1)`, false},
+		{`eq(len, len)`, true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`eq(1)`), "wrong number of arguments. got=1, want=2")
+}
+
+func TestBuiltinScan(t *testing.T) {
+	testInspect(t, `scan([1, 2, 3], 0, fn(acc, x) { acc + x })`, "[0, 1, 3, 6]")
+	testInspect(t, `scan([], 10, fn(acc, x) { acc + x })`, "[10]")
+	testInspect(t, `scan(["a", "b"], "", fn(acc, x) { acc + x })`, "[, a, ab]")
+	testErrorObject(t, testEval(`scan([1], 0, fn(x) { x })`),
+		"wrong number of arguments: want=1, got=2")
+	testErrorObject(t, testEval(`scan(1, 0, fn(acc, x) { x })`),
+		"first argument to `scan` must be ARRAY, got INTEGER")
+	testErrorObject(t, testEval(`scan([1], 0)`), "wrong number of arguments. got=2, want=3")
+}
+
+func TestBuiltinClamp(t *testing.T) {
+	testIntegerObject(t, testEval(`clamp(5, 0, 3)`), 3)
+	testIntegerObject(t, testEval(`clamp(-1, 0, 3)`), 0)
+	testIntegerObject(t, testEval(`clamp(2, 0, 3)`), 2)
+	testIntegerObject(t, testEval(`clamp(3, 3, 3)`), 3)
+	testIntegerObject(t, testEval(`clamp(1.5, 0, 1)`), 1)
+	testFloatObject(t, testEval(`clamp(0.25, 0, 1)`), 0.25)
+	testFloatObject(t, testEval(`clamp(-2, -1.5, 0)`), -1.5)
+	testErrorObject(t, testEval(`clamp(1, 3, 0)`),
+		"lower bound of `clamp` must not exceed upper bound, got 3 > 0")
+	testErrorObject(t, testEval(`clamp("a", 0, 1)`),
+		"arguments to `clamp` must be INTEGER or FLOAT, got STRING")
+	testErrorObject(t, testEval(`clamp(1, 2)`), "wrong number of arguments. got=2, want=3")
+}
+
+func TestBuiltinToPairsFromPairs(t *testing.T) {
+	testInspect(t, `to_pairs({"b": 2, "a": 1, 3: true})`, "[[b, 2], [a, 1], [3, true]]")
+	testInspect(t, `to_pairs({})`, "[]")
+	testInspect(t, `from_pairs([["b", 2], ["a", 1]])`, "{b: 2, a: 1}")
+	testInspect(t, `from_pairs([["a", 1], ["a", 2]])`, "{a: 2}")
+	testInspect(t, `from_pairs([])`, "{}")
+
+	// Round trips keep both the entries and their order.
+	testInspect(t, `from_pairs(to_pairs({"x": [1], "y": {"z": 2}, true: 0}))`,
+		`{x: [1], y: {z: 2}, true: 0}`)
+	testInspect(t, `to_pairs(from_pairs([[2, "b"], [1, "a"]]))`, "[[2, b], [1, a]]")
+
+	testErrorObject(t, testEval(`to_pairs([1])`), "argument to `to_pairs` must be HASH, got ARRAY")
+	testErrorObject(t, testEval(`from_pairs({})`), "argument to `from_pairs` must be ARRAY, got HASH")
+	testErrorObject(t, testEval(`from_pairs([["a", 1], ["b"]])`),
+		"element 1 of `from_pairs` must be a [key, value] ARRAY, got [b]")
+	testErrorObject(t, testEval(`from_pairs([1])`),
+		"element 0 of `from_pairs` must be a [key, value] ARRAY, got 1")
+	testErrorObject(t, testEval(`from_pairs([[[1], 2]])`), "unusable as hash key: ARRAY")
+}
+
+func TestBuiltinCount(t *testing.T) {
+	testIntegerObject(t, testEval(`count([1, 2, 2, 3], 2)`), 2)
+	testIntegerObject(t, testEval(`count([1, 2, 2, 3], 4)`), 0)
+	testIntegerObject(t, testEval(`count([], 1)`), 0)
+	testIntegerObject(t, testEval(`count([[1], [1, 2], [1]], [1])`), 2)
+	testIntegerObject(t, testEval(`count(["a", "b", "a"], "a")`), 2)
+	testErrorObject(t, testEval(`count("aa", "a")`), "first argument to `count` must be ARRAY, got STRING")
+	testErrorObject(t, testEval(`count([1])`), "wrong number of arguments. got=1, want=2")
+}
+
+func TestBuiltinCountBy(t *testing.T) {
+	testIntegerObject(t, testEval(`count_by([1, 2, 3, 4], fn(x) { x % 2 == 0 })`), 2)
+	testIntegerObject(t, testEval(`count_by([1, 3], fn(x) { x % 2 == 0 })`), 0)
+	testIntegerObject(t, testEval(`count_by([], fn(x) { true })`), 0)
+	testErrorObject(t, testEval(`count_by([1], fn(x) { x })`),
+		"predicate passed to `count_by` must return BOOLEAN, got INTEGER")
+	testErrorObject(t, testEval(`count_by({}, fn(x) { true })`),
+		"first argument to `count_by` must be ARRAY, got HASH")
+	testErrorObject(t, testEval(`count_by([1], fn(x) { x + true })`),
+		"type mismatch: INTEGER + BOOLEAN")
+}
+
+func TestBuiltinPickOmit(t *testing.T) {
+	testInspect(t, `pick({"a": 1, "b": 2, "c": 3}, ["c", "a"])`, "{a: 1, c: 3}")
+	testInspect(t, `pick({"a": 1}, ["a", "z"])`, "{a: 1}")
+	testInspect(t, `pick({"a": 1}, [])`, "{}")
+	testInspect(t, `omit({"a": 1, "b": 2, "c": 3}, ["a"])`, "{b: 2, c: 3}")
+	testInspect(t, `omit({"a": 1}, ["z"])`, "{a: 1}")
+	testInspect(t, `omit({1: "x", true: "y"}, [true])`, "{1: x}")
+
+	// The original hash is left alone.
+	testInspect(t, `let h = {"a": 1, "b": 2}; omit(h, ["a"]); h`, "{a: 1, b: 2}")
+
+	testErrorObject(t, testEval(`pick([1], ["a"])`), "first argument to `pick` must be HASH, got ARRAY")
+	testErrorObject(t, testEval(`omit({}, "a")`), "second argument to `omit` must be ARRAY, got STRING")
+	testErrorObject(t, testEval(`pick({}, [[1]])`), "unusable as hash key: ARRAY")
+	testErrorObject(t, testEval(`omit({})`), "wrong number of arguments. got=1, want=2")
+}
+
+func TestBuiltinSortBy(t *testing.T) {
+	testInspect(t, `sort_by([3, -1, 2, -4], fn(x) { x * x })`, "[-1, 2, 3, -4]")
+	testInspect(t, `sort_by([2.5, 1, 2], fn(x) { x })`, "[1, 2, 2.5]")
+	testInspect(t, `sort_by(["pear", "fig", "apple"], fn(s) { s })`, "[apple, fig, pear]")
+	testInspect(t, `sort_by([], fn(x) { x })`, "[]")
+
+	// Equal keys keep their original order.
+	testInspect(t, `sort_by([["a", 2], ["b", 1], ["c", 2], ["d", 1]], fn(x) { x[1] })`,
+		"[[b, 1], [d, 1], [a, 2], [c, 2]]")
+	testInspect(t, `sort_by([5, 3, 8, 1], fn(x) { 0 })`, "[5, 3, 8, 1]")
+
+	// The key function runs once per element.
+	env, seen := newRecordingEnvironment()
+	testEvalEnv(`sort_by([3, 1, 2], fn(x) { record(x); x })`, env)
+	if len(*seen) != 3 {
+		t.Errorf("key function called %d times, want 3", len(*seen))
+	}
+
+	testErrorObject(t, testEval(`sort_by([1, "a"], fn(x) { x })`), "cannot compare STRING and INTEGER")
+	testErrorObject(t, testEval(`sort_by([[1]], fn(x) { x })`),
+		"key passed to `sort_by` must be INTEGER, FLOAT or STRING, got ARRAY")
+	testErrorObject(t, testEval(`sort_by("ab", fn(x) { x })`),
+		"first argument to `sort_by` must be ARRAY, got STRING")
+	testErrorObject(t, testEval(`sort_by([1], 1)`),
+		"second argument to `sort_by` must be FUNCTION, got INTEGER")
+}
+
+func TestBuiltinFunctionArguments(t *testing.T) {
+	// The function is checked up front, so empty input doesn't hide a
+	// wrong argument.
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`each([], 1)`, "second argument to `each` must be FUNCTION, got INTEGER"},
+		{`each({}, "f")`, "second argument to `each` must be FUNCTION, got STRING"},
+		{`update([], 1)`, "second argument to `update` must be FUNCTION, got INTEGER"},
+		{`group_by([], 1)`, "second argument to `group_by` must be FUNCTION, got INTEGER"},
+		{`zip_with([], [], 1)`, "third argument to `zip_with` must be FUNCTION, got INTEGER"},
+		{`find([], 1)`, "second argument to `find` must be FUNCTION, got INTEGER"},
+		{`partition([], 1)`, "second argument to `partition` must be FUNCTION, got INTEGER"},
+		{`scan([], 0, 1)`, "third argument to `scan` must be FUNCTION, got INTEGER"},
+		{`count_by([], 1)`, "second argument to `count_by` must be FUNCTION, got INTEGER"},
+		{`max_by([], 1)`, "second argument to `max_by` must be FUNCTION, got INTEGER"},
+		{`min_by([1], true)`, "second argument to `min_by` must be FUNCTION, got BOOLEAN"},
+		{`sort_by([], 1)`, "second argument to `sort_by` must be FUNCTION, got INTEGER"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+	testIntegerObject(t, testEval(`count_by([], fn(x) { true })`), 0)
+	testInspect(t, `update([-1, 2], abs)`, "[1, 2]")
+}
+
+func TestBuiltinMinMax(t *testing.T) {
+	testInspect(t, `min_max([3, 1, 2])`, "[1, 3]")
+	testInspect(t, `min_max([-5, 10, 0, 10, -5])`, "[-5, 10]")
+	testInspect(t, `min_max([2.5, 1, 4])`, "[1, 4]")
+	testInspect(t, `min_max([7])`, "[7, 7]")
+	testErrorObject(t, testEval(`min_max([])`), "`min_max` of empty array")
+	testErrorObject(t, testEval(`min_max([1, "a"])`), "`min_max` requires an array of numbers, got STRING")
+	testErrorObject(t, testEval(`min_max(1)`), "argument to `min_max` must be ARRAY, got INTEGER")
+}
@@ -0,0 +1,38 @@
+package test
+
+import (
+	"fmt"
+	"monkey_kd/object"
+	"sync"
+	"testing"
+)
+
+func TestSyncEnvironmentConcurrentAccess(t *testing.T) {
+	env := object.NewSyncEnvironment()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("v%d", i)
+			for j := 0; j < 100; j++ {
+				env.Set(name, &object.Integer{Value: int64(j)})
+				env.Set("shared", &object.Integer{Value: int64(i)})
+				env.Get("shared")
+				env.Get(name)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := 0; i < 8; i++ {
+		val, ok := env.Get(fmt.Sprintf("v%d", i))
+		if !ok {
+			t.Fatalf("v%d not set", i)
+		}
+		testIntegerObject(t, val, 99)
+	}
+	enclosed := object.NewEnclosedEnvironment(env)
+	if _, ok := enclosed.Get("shared"); !ok {
+		t.Errorf("enclosed environment cannot see outer sync binding")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"monkey_kd/lexer"
 	"monkey_kd/object"
 	"monkey_kd/parser"
+	"strings"
 	"testing"
 )
 
@@ -15,8 +16,15 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}{
 		{"5", 5},
 		{"10", 10},
+		{"13 % 5", 3},
+		{"10 % 3 * 2", 2},
+		{"1 + 7 % 4", 4},
+		{"-7 % 3", -1},
 		{"-5", -5},
 		{"-10", -10},
+		{"+5", 5},
+		{"-+5", -5},
+		{"5 + +5", 10},
 		{"5 + 5 + 5 + 5- 10", 10},
 		{"2 * 2 * 2 * 2 * 2", 32},
 		{"-50 + 100 +-50", 0},
@@ -81,11 +89,28 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" != "a"`, false},
+		{`"" == ""`, true},
+		{`let s = "mon"; s + "key" == "monkey"`, true},
+		// null compares with anything: it is equal only to null.
+		{`first([]) == 1`, false},
+		{`first([]) != 1`, true},
+		{`1 == first([])`, false},
+		{`first([]) == "a"`, false},
+		{`find([1, 2], fn(x) { x > 5 }) == first([])`, true},
+		{`first([]) != first([])`, false},
+		{`first([1]) == 1`, true},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
 		testBooleanObject(t, evaluated, tt.expected)
 	}
+	testErrorObject(t, testEval(`"1" == 1`), "type mismatch: STRING == INTEGER")
+	testErrorObject(t, testEval(`true != "true"`), "type mismatch: BOOLEAN != STRING")
+	testErrorObject(t, testEval(`first([]) + 1`), "type mismatch: NULL + INTEGER")
 }
 
 func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
@@ -114,6 +139,13 @@ func TestBangOperator(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		// Only false and null are falsy, so !! casts everything else to true.
+		{"!!0", true},
+		{`!!""`, true},
+		{"!![]", true},
+		{"!!{}", true},
+		{"!!if (false) { 1 }", false},
+		{"!!!0", false},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -134,6 +166,9 @@ func TestIfElseExpressions(t *testing.T) {
 		{"if (1 > 2) { 10 }", nil},
 		{"if (1 > 2) { 10 } else { 20 }", 20},
 		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 > 2) { 10 } else if (2 > 1) { 20 } else { 30 }", 20},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 } else { 30 }", 30},
+		{"if (1 > 2) { 10 } else if (2 > 3) { 20 }", nil},
 	}
 	for _, tt := range tests {
 		evaluated := testEval(tt.input)
@@ -163,6 +198,8 @@ func TestEvalReturnStatements(t *testing.T) {
 		{"return 10; 9;", 10},
 		{"return 2 * 5; 9;", 10},
 		{"9; return 2 * 5; 9;", 10},
+		{"let f = fn() { if (true) { return 5 } }; f()", 5},
+		{"let f = fn() { let x = 7 }; f(); 7", 7},
 		{
 			`
 if (10 > 1) {
@@ -189,7 +226,11 @@ func TestErrorHandling(t *testing.T) {
 		{"5 + true; 5;",
 			"type mismatch: INTEGER + BOOLEAN"},
 		{"-true",
-			"unknown operator:-BOOLEAN"},
+			"unknown operator: -BOOLEAN"},
+		{"+true",
+			"unknown operator: +BOOLEAN"},
+		{`+"x"`,
+			"unknown operator: +STRING"},
 		{"true + false;",
 			"unknown operator: BOOLEAN + BOOLEAN"},
 		{"5; true + false; 5",
@@ -282,3 +323,865 @@ func TestClosures(t *testing.T) {
  addTwo(2);`
 	testIntegerObject(t, testEval(input), 4)
 }
+
+func TestStringLiteral(t *testing.T) {
+	testStringObject(t, testEval(`"Hello World!"`), "Hello World!")
+}
+
+func TestStringConcatenation(t *testing.T) {
+	testStringObject(t, testEval(`"Hello" + " " + "World!"`), "Hello World!")
+	testStringObject(t, testEval(`"foo" + "bar"`), "foobar")
+	testStringObject(t, testEval(`"" + ""`), "")
+	testStringObject(t, testEval(`"" + "x"`), "x")
+	testStringObject(t, testEval(`"x" + ""`), "x")
+	testStringObject(t, testEval(`let s = "a"; s + s + s`), "aaa")
+
+	testErrorObject(t, testEval(`"foo" - "bar"`), "unknown operator: STRING - STRING")
+	testErrorObject(t, testEval(`"foo" * "bar"`), "unknown operator: STRING * STRING")
+	testErrorObject(t, testEval(`"foo" / ""`), "unknown operator: STRING / STRING")
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+	}
+	for _, tt := range tests {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+	testBooleanObject(t, testEval("1.5 < 2"), true)
+	testBooleanObject(t, testEval("2.0 == 2"), true)
+}
+
+func TestIsolatedEnvironments(t *testing.T) {
+	inputs := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; let f = fn() { x }; f();", 1},
+		{"let x = 2; let f = fn() { x * 10 }; f();", 20},
+	}
+	results := make([]object.Object, len(inputs))
+	done := make(chan int)
+	for i, tt := range inputs {
+		go func(i int, input string) {
+			results[i] = testEval(input)
+			done <- i
+		}(i, tt.input)
+	}
+	for range inputs {
+		<-done
+	}
+	for i, tt := range inputs {
+		testIntegerObject(t, results[i], tt.expected)
+	}
+
+	first := object.NewEnvironment()
+	second := object.NewEnvironment()
+	evaluator.Eval(parser.New(lexer.New("let x = 5;")).ParseProgram(), first)
+	evaluator.Eval(parser.New(lexer.New("let x = true;")).ParseProgram(), second)
+	x, _ := first.Get("x")
+	testIntegerObject(t, x, 5)
+	x, _ = second.Get("x")
+	testBooleanObject(t, x, true)
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+	if result.Inspect() != "{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}" {
+		t.Errorf("hash not in insertion order. got=%q", result.Inspect())
+	}
+}
+
+func TestNumericBoundaryLiterals(t *testing.T) {
+	testIntegerObject(t, testEval("-0"), 0)
+	testBooleanObject(t, testEval("-0 == 0"), true)
+	testFloatObject(t, testEval("0.0"), 0)
+	testBooleanObject(t, testEval("-0.0 == 0.0"), true)
+	testBooleanObject(t, testEval("-0.0 == 0"), true)
+	testIntegerObject(t, testEval("9223372036854775807"), 9223372036854775807)
+	testIntegerObject(t, testEval("-9223372036854775807"), -9223372036854775807)
+	testIntegerObject(t, testEval("-9223372036854775807 - 1"), -9223372036854775808)
+	// Leading zeros are decimal, not octal.
+	testIntegerObject(t, testEval("010"), 10)
+	testIntegerObject(t, testEval("09"), 9)
+	testIntegerObject(t, testEval("-007"), -7)
+	testFloatObject(t, testEval("010.5"), 10.5)
+	testIntegerObject(t, testEval("0xFF"), 255)
+	testIntegerObject(t, testEval("0x1f + 0X10"), 47)
+	testIntegerObject(t, testEval("0b101"), 5)
+	testIntegerObject(t, testEval("0o17"), 15)
+	testIntegerObject(t, testEval("-0x10"), -16)
+	testIntegerObject(t, testEval("0x7FFFFFFFFFFFFFFF"), 9223372036854775807)
+
+	huge := "1" + strings.Repeat("0", 400) + ".0"
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "1:1: integer literal 9223372036854775808 out of range"},
+		{"-9223372036854775808", "1:2: integer literal 9223372036854775808 out of range"},
+		{"99999999999999999999", "1:1: integer literal 99999999999999999999 out of range"},
+		{"0x8000000000000000", "1:1: integer literal 0x8000000000000000 out of range"},
+		{"0xZZ", `1:1: could not parse "0xZZ" as integer`},
+		{"0b102", `1:1: could not parse "0b102" as integer`},
+		{"0o8", `1:1: could not parse "0o8" as integer`},
+		{"0x", `1:1: could not parse "0x" as integer`},
+		{huge, "1:1: float literal " + huge + " out of range"},
+	}
+	for _, tt := range errorTests {
+		parse := parser.New(lexer.New(tt.input))
+		parse.ParseProgram()
+		errors := parse.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong parser errors for %q. expected=[%q], got=%q",
+				tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestStringRepetition(t *testing.T) {
+	testStringObject(t, testEval(`"ab" * 3`), "ababab")
+	testStringObject(t, testEval(`3 * "ab"`), "ababab")
+	testStringObject(t, testEval(`"ab" * 0`), "")
+	testStringObject(t, testEval(`"-" * 2 + "x"`), "--x")
+	testErrorObject(t, testEval(`"ab" * -1`), "negative repeat count: -1")
+	testErrorObject(t, testEval(`-2 * "ab"`), "negative repeat count: -2")
+	testErrorObject(t, testEval(`"ab" * "cd"`), "unknown operator: STRING * STRING")
+	testErrorObject(t, testEval(`"ab" * 9223372036854775807`),
+		"repeated string too long: 9223372036854775807 copies of 2 bytes exceeds 16777216 bytes")
+	testErrorObject(t, testEval(`4611686018427387904 * "ab"`),
+		"repeated string too long: 4611686018427387904 copies of 2 bytes exceeds 16777216 bytes")
+	testStringObject(t, testEval(`"" * 9223372036854775807`), "")
+}
+
+func TestNestedHashLiterals(t *testing.T) {
+	evaluated := testEval(`{"a": {"b": [1, true], "c": "x"}, "d": false}`)
+	outer, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+	pair, ok := outer.Pairs[(&object.String{Value: "a"}).HashKey()]
+	if !ok {
+		t.Fatalf("no pair for key \"a\"")
+	}
+	inner, ok := pair.Value.(*object.Hash)
+	if !ok {
+		t.Fatalf("value of \"a\" is not Hash. got=%T", pair.Value)
+	}
+	array, ok := inner.Pairs[(&object.String{Value: "b"}).HashKey()].Value.(*object.Array)
+	if !ok {
+		t.Fatalf("value of \"b\" is not Array")
+	}
+	testIntegerObject(t, array.Elements[0], 1)
+	testBooleanObject(t, array.Elements[1], true)
+	if outer.Inspect() != "{a: {b: [1, true], c: x}, d: false}" {
+		t.Errorf("Inspect() wrong. got=%q", outer.Inspect())
+	}
+}
+
+func testSameObject(t *testing.T, input string, expected object.Object) bool {
+	evaluated := testEval(input)
+	if evaluated != expected {
+		t.Errorf("%s did not return the shared object %p. got=%p (%+v)",
+			input, expected, evaluated, evaluated)
+		return false
+	}
+	return true
+}
+
+func TestBooleanSingletons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected object.Object
+	}{
+		{"true", evaluator.TRUE},
+		{"false", evaluator.FALSE},
+		{"1 < 2", evaluator.TRUE},
+		{"1.5 > 2", evaluator.FALSE},
+		{"!5", evaluator.FALSE},
+		{"true == true", evaluator.TRUE},
+		{"[1] == [1]", evaluator.FALSE},
+		{`"a" == "a"`, evaluator.TRUE},
+	}
+	for _, tt := range tests {
+		testSameObject(t, tt.input, tt.expected)
+	}
+	if testEval("true") != testEval("1 == 1") {
+		t.Errorf("repeated true evaluations returned different objects")
+	}
+}
+
+func TestFunctionArgumentCount(t *testing.T) {
+	testErrorObject(t, testEval("fn(x, y) { x }(1)"), "wrong number of arguments: want=2, got=1")
+	testErrorObject(t, testEval("fn() { 1 }(1)"), "wrong number of arguments: want=0, got=1")
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{"let myArray = [1, 2, 3]; myArray[99];", nil},
+		{"[][0]", nil},
+		{"let arr = [10, 20, 30]; let compute = fn() { 1 }; let x = arr[compute()]; x", 20},
+		{"let f = fn() { [7, 8] }; f()[0]", 7},
+		{"let f = fn() { [fn(x) { x * 2 }] }; f()[0](21)", 42},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+	testErrorObject(t, testEval(`{"name": "Monkey"}[fn(x) { x }];`),
+		"unusable as hash key: FUNCTION")
+	testErrorObject(t, testEval(`1[0]`), "index operator not supported: INTEGER")
+}
+
+func TestIndexEvaluatesOnce(t *testing.T) {
+	calls := 0
+	env := object.NewEnvironment()
+	env.Set("compute", &object.Builtin{
+		Fn: func(env *object.Environment, args ...object.Object) object.Object {
+			calls++
+			return &object.Integer{Value: 1}
+		},
+	})
+	program := parser.New(lexer.New("let arr = [1, 2]; let x = arr[compute()]; x")).ParseProgram()
+	testIntegerObject(t, evaluator.Eval(program, env), 2)
+	if calls != 1 {
+		t.Errorf("compute() called %d times, want 1", calls)
+	}
+}
+
+func TestChainedAccessors(t *testing.T) {
+	input := `
+let data = {"users": [{"name": "ann", "tags": ["a", "b"]}, {"name": "bob"}]};
+data["users"][0].name + data.users[1].name + data.users[0].tags[1]`
+	testStringObject(t, testEval(input), "annbobb")
+	testNullObject(t, testEval(`{"a": 1}.b`))
+	testIntegerObject(t, testEval(`let f = fn() { {"x": 3} }; f().x`), 3)
+	testErrorObject(t, testEval(`[1].name`), "property access not supported: ARRAY.name")
+}
+
+func TestDeferStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		result   int64
+		expected string
+	}{
+		{`let f = fn() { defer record(1); defer record(2); record(0); 5 }; f()`, 5, "0,2,1"},
+		{`let f = fn() { defer record("d"); return 1; record("x") }; f()`, 1, "d"},
+		{`let f = fn(x) { defer record(x); if (x > 0) { defer record(x * 10); return x; } 0 }; f(3)`, 3, "30,3"},
+		{`let f = fn() { let x = 1; defer record(x); let x = 2; x }; f()`, 2, "2"},
+		{`defer record("end"); record("start"); 7`, 7, "start,end"},
+	}
+	for _, tt := range tests {
+		env, seen := newRecordingEnvironment()
+		testIntegerObject(t, testEvalEnv(tt.input, env), tt.result)
+		if strings.Join(*seen, ",") != tt.expected {
+			t.Errorf("deferred calls wrong for %s. expected=%q, got=%q",
+				tt.input, tt.expected, strings.Join(*seen, ","))
+		}
+	}
+	testErrorObject(t, testEval(`let f = fn() { defer 1 + true; 5 }; f()`),
+		"type mismatch: INTEGER + BOOLEAN")
+}
+
+func TestPrefixOperatorsOnCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-[1]", "unknown operator: -ARRAY"},
+		{`-"x"`, "unknown operator: -STRING"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{`-{"a": 1}`, "unknown operator: -HASH"},
+		{"-fn() { 1 }", "unknown operator: -FUNCTION"},
+		{"+[1]", "unknown operator: +ARRAY"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+	testBooleanObject(t, testEval("![1, 2]"), false)
+	testBooleanObject(t, testEval("!{}"), false)
+}
+
+func TestMethodCalls(t *testing.T) {
+	testIntegerObject(t, testEval(`"abc".len()`), 3)
+	testIntegerObject(t, testEval(`[1, 2, 3].len()`), 3)
+	testIntegerObject(t, testEval(`let s = "hello"; s.len() + len(s)`), 10)
+	testIntegerObject(t, testEval(`{"len": fn() { 99 }}.len()`), 99)
+	testErrorObject(t, testEval(`5.len()`), "property access not supported: INTEGER.len")
+	testErrorObject(t, testEval(`{"a": 1}.len()`), "not a function: NULL")
+	testErrorObject(t, testEval(`"abc".len(1)`), "wrong number of arguments. got=2, want=1")
+}
+
+func TestEvalAll(t *testing.T) {
+	program := parser.New(lexer.New("let x = 2; x * 3; x + 1;")).ParseProgram()
+	results := evaluator.EvalAll(program, object.NewEnvironment())
+	if len(results) != 3 {
+		t.Fatalf("wrong number of results. expected=3, got=%d", len(results))
+	}
+	if results[0] != nil {
+		t.Errorf("let statement result not nil. got=%+v", results[0])
+	}
+	testIntegerObject(t, results[1], 6)
+	testIntegerObject(t, results[2], 3)
+
+	program = parser.New(lexer.New("1; return 2; 3; 4")).ParseProgram()
+	results = evaluator.EvalAll(program, object.NewEnvironment())
+	if len(results) != 2 {
+		t.Fatalf("return did not stop evaluation. got=%d results", len(results))
+	}
+	testIntegerObject(t, results[1], 2)
+
+	program = parser.New(lexer.New("1; foo; 3")).ParseProgram()
+	results = evaluator.EvalAll(program, object.NewEnvironment())
+	if len(results) != 2 {
+		t.Fatalf("error did not stop evaluation. got=%d results", len(results))
+	}
+	testErrorObject(t, results[1], "identifier not found: foo")
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		strict   string
+		operands string
+	}{
+		{`true && false`, "false", "false"},
+		{`false || true`, "true", "true"},
+		{`0 || 5`, "true", "0"},
+		{`"" && "x"`, "true", "x"},
+		{`false || "default"`, "true", "default"},
+		{`if (false) { 1 } && 2`, "false", "null"},
+		{`1 && 2 && 3`, "true", "3"},
+	}
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		if got := testEvalEnv(tt.input, env).Inspect(); got != tt.strict {
+			t.Errorf("%s (strict): expected=%s, got=%s", tt.input, tt.strict, got)
+		}
+		env = object.NewEnvironment()
+		env.Options().OperandLogic = true
+		if got := testEvalEnv(tt.input, env).Inspect(); got != tt.operands {
+			t.Errorf("%s (operands): expected=%s, got=%s", tt.input, tt.operands, got)
+		}
+	}
+}
+
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	testBooleanObject(t, testEval(`false && missing`), false)
+	testBooleanObject(t, testEval(`true || missing`), true)
+	testErrorObject(t, testEval(`true && missing`), "identifier not found: missing")
+
+	// Options are shared with function environments.
+	env := object.NewEnvironment()
+	env.Options().OperandLogic = true
+	testIntegerObject(t, testEvalEnv(`let f = fn(x) { x || 7 }; f(false)`, env), 7)
+}
+
+func TestLetRedeclaration(t *testing.T) {
+	tests := []struct {
+		input  string
+		strict interface{}
+		loose  interface{}
+	}{
+		{"let x = 1; let x = 2; x", "x already declared", 2},
+		{"let x = 1; let f = fn() { let x = 2; x }; f()", 2, 2},
+		{"let x = 1; let f = fn(x) { let y = x; y }; f(5)", 5, 5},
+		{"let f = fn() { let y = 1; let y = 2; y }; f()", "y already declared", 2},
+		{"let x = 1; if (true) { let x = 2; }; x", "x already declared", 2},
+		{"let i = 0; let t = 0; while (i < 3) { let d = i * 2; t = t + d; i = i + 1 }; t", 6, 6},
+		{"let f = fn() { let y = 1; y }; f() + f()", 2, 2},
+		{"let d = 1; let i = 0; while (i < 2) { let d = i; i = i + 1 }; d", "d already declared", 1},
+	}
+	for _, tt := range tests {
+		for _, strict := range []bool{true, false} {
+			env := object.NewEnvironment()
+			env.Options().StrictLet = strict
+			evaluated := testEvalEnv(tt.input, env)
+			expected := tt.loose
+			if strict {
+				expected = tt.strict
+			}
+			switch expected := expected.(type) {
+			case int:
+				testIntegerObject(t, evaluated, int64(expected))
+			case string:
+				testErrorObject(t, evaluated, expected)
+			}
+		}
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = x + 1; x", 2},
+		{"let x = 1; x = 5", 5},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		{"let i = 0; while (i < 3) { i = i + 1 }; i", 3},
+		{"let n = 0; let inc = fn() { n = n + 1 }; inc(); inc(); n", 2},
+		{"let x = 1; let f = fn() { let x = 10; x = 20; x }; f() + x", 21},
+		{"let x = 1; let f = fn(x) { x = x * 2; x }; f(4) + x", 9},
+		{"let x = 1; if (true) { let x = 2; }; x", 2},
+		{"y = 1", "cannot assign to undeclared identifier: y"},
+		{"let f = fn() { let z = 1; }; f(); z = 2", "cannot assign to undeclared identifier: z"},
+		{"let x = 1; x = missing", "identifier not found: missing"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestCompoundAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 10; x -= 3; x", 7},
+		{"let x = 10; x += 5", 15},
+		{"let x = 10; x *= 2; x /= 4; x", 5},
+		{"let x = 1; let f = fn() { x += 1 }; f(); f(); x", 3},
+		{"let x = 1; let y = 2; x += y *= 3; x", 7},
+		{`let s = "ab"; s += "c"; s`, "abc"},
+		{"let x = 1.5; x *= 2; x", 3.0},
+		{"y += 1", "cannot assign to undeclared identifier: y"},
+		{"let x = 1; x /= 0", "division by zero"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			if str, ok := evaluated.(*object.String); ok {
+				if str.Value != expected {
+					t.Errorf("%q: wrong string. got=%q", tt.input, str.Value)
+				}
+				continue
+			}
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestInOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`2 in [1, 2, 3]`, true},
+		{`4 in [1, 2, 3]`, false},
+		{`[1, 2] in [[1, 2], [3]]`, true},
+		{`2.0 in [1, 2]`, true},
+		{`"a" in []`, false},
+		{`"a" in {"a": 1, "b": 2}`, true},
+		{`"c" in {"a": 1, "b": 2}`, false},
+		{`1 in {"1": true}`, false},
+		{`let k = "b"; k in {"b": false}`, true},
+	}
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`"a" in "abc"`),
+		"right operand of `in` must be ARRAY or HASH, got STRING")
+	testErrorObject(t, testEval(`[1] in {}`), "unusable as hash key: ARRAY")
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{
+		"1 / 0",
+		"1 % 0",
+		"let x = 0; 10 / x",
+		"fn() { 5 % (2 - 2) }()",
+	}
+	for _, input := range tests {
+		testErrorObject(t, testEval(input), "division by zero")
+	}
+	testFloatObject(t, testEval("5.5 % 2"), 1.5)
+}
+
+func TestRangeOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1..5", "[1, 2, 3, 4, 5]"},
+		{"3..3", "[3]"},
+		{"3..1", "[3, 2, 1]"},
+		{"-1..1", "[-1, 0, 1]"},
+		{"let n = 2; 0..n * 2", "[0, 1, 2, 3, 4]"},
+		{"1.5", "1.5"},
+		{"9223372036854775806..9223372036854775807", "[9223372036854775806, 9223372036854775807]"},
+	}
+	for _, tt := range tests {
+		testInspect(t, tt.input, tt.expected)
+	}
+	testErrorObject(t, testEval(`1..2.5`), "range bounds must be INTEGER, got FLOAT")
+	testErrorObject(t, testEval(`"a"..3`), "range bounds must be INTEGER, got STRING")
+	testErrorObject(t, testEval(`1..9223372036854775807`),
+		"range 1..9223372036854775807 exceeds 16777216 elements")
+	testErrorObject(t, testEval(`9223372036854775807..(-9223372036854775807 - 1)`),
+		"range 9223372036854775807..-9223372036854775808 exceeds 16777216 elements")
+	testErrorObject(t, testEval(`0..16777216`), "range 0..16777216 exceeds 16777216 elements")
+}
+
+func TestHashLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{}`, "{}"},
+		{`let h = {}; h`, "{}"},
+		{`{1: "int", "1": "str", true: "bool"}`, "{1: int, 1: str, true: bool}"},
+		{`let h = {1: "int", "1": "str", true: "bool"}; [h[1], h["1"], h[true]]`, "[int, str, bool]"},
+		{`if (true) { {"a": 1} }`, "{a: 1}"},
+		{`fn() { {"b": 2} }()["b"]`, "2"},
+		{`{"a": 1, "a": 2}`, "{a: 2}"},
+	}
+	for _, tt := range tests {
+		testInspect(t, tt.input, tt.expected)
+	}
+}
+
+func TestLengthProperty(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`[1, 2, 3].length`, 3},
+		{`[].length`, 0},
+		{`"héllo".length`, 5},
+		{`let s = ""; s.length`, 0},
+		{`{"a": 1, "b": 2}.length`, 2},
+		{`{"length": 10}.length`, 1},
+		{`let xs = [[1, 2], [3]]; xs[0].length + xs.length`, 4},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+	testErrorObject(t, testEval(`5.length`), "property access not supported: INTEGER.length")
+}
+
+func TestExit(t *testing.T) {
+	tests := []struct {
+		input string
+		code  int64
+	}{
+		{`exit()`, 0},
+		{`exit(3); 5`, 3},
+		{`let f = fn() { exit(4); 1 }; let g = fn() { f() + 1 }; g(); 99`, 4},
+		{`each([1, 2], fn(x) { if (x == 2) { exit(x) } }); 7`, 2},
+		{`let f = fn() { defer record("deferred"); exit(1) }; f()`, 1},
+	}
+	for _, tt := range tests {
+		env, seen := newRecordingEnvironment()
+		evaluated := testEvalEnv(tt.input, env)
+		exit, ok := evaluated.(*object.Exit)
+		if !ok {
+			t.Errorf("%s: object is not Exit. got=%T (%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if exit.Code != tt.code {
+			t.Errorf("%s: wrong exit code. expected=%d, got=%d", tt.input, tt.code, exit.Code)
+		}
+		if len(*seen) != 0 {
+			t.Errorf("%s: recorded %q after exit", tt.input, *seen)
+		}
+	}
+	testErrorObject(t, testEval(`exit("no")`), "argument to `exit` must be INTEGER, got STRING")
+}
+
+func TestExitSkipsLaterStatements(t *testing.T) {
+	env, seen := newRecordingEnvironment()
+	testEvalEnv(`record(1); exit(0); record(2)`, env)
+	if len(*seen) != 1 || (*seen)[0] != "1" {
+		t.Errorf("statements after exit ran. recorded=%q", *seen)
+	}
+	results := evaluator.EvalAll(parser.New(lexer.New(`1; exit(2); 3`)).ParseProgram(),
+		object.NewEnvironment())
+	if len(results) != 2 || results[1].Inspect() != "exit(2)" {
+		t.Errorf("EvalAll did not stop at exit. got=%v", results)
+	}
+}
+
+func TestExitDropsProgramDefers(t *testing.T) {
+	// A defer left pending by exit must not run with the next program
+	// evaluated in the same environment.
+	env, seen := newRecordingEnvironment()
+	testEvalEnv(`defer record("deferred"); exit(1)`, env)
+	testIntegerObject(t, testEvalEnv(`2`, env), 2)
+	evaluator.EvalAll(parser.New(lexer.New(`defer record("deferred"); exit(1)`)).ParseProgram(), env)
+	if _, errs := evaluator.EvalSource(`3`, env); errs != nil {
+		t.Fatalf("unexpected parse errors: %v", errs)
+	}
+	if len(*seen) != 0 {
+		t.Errorf("deferred call ran after exit. recorded=%q", *seen)
+	}
+}
+
+func TestWhileValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; let x = while (i < 3) { let i = i + 1; i * 10 }; x", 30},
+		{"let x = while (false) { 1 }; x", nil},
+		{"let i = 0; while (i < 3) { let i = i + 1; i * 10 }", nil},
+		{"let i = 0; while (i < 3) { let i = i + 1; i * 10 }; i", 3},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 4) { return i } } }; f()", 4},
+		{"let i = 0; [while (i < 2) { let i = i + 1; i }][0]", 2},
+		{"let i = 0; while (i < 3) { let i = i + 1; }; i", 3},
+		{"let i = 5; while (i < 3) { let i = i + 1; }; i", 5},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+	testErrorObject(t, testEval("let i = 0; while (i < 3) { let i = i + true; }"),
+		"type mismatch: INTEGER + BOOLEAN")
+	// The condition holds twice, then errors; the loop stops with that error.
+	testErrorObject(t, testEval(
+		"let i = 0; while (if (i < 2) { true } else { i + true }) { let i = i + 1; }"),
+		"type mismatch: INTEGER + BOOLEAN")
+	testErrorObject(t, testEval("while (missing) { 1 }"), "identifier not found: missing")
+}
+
+func TestLogicalOperatorsSkipRightOperand(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+		calls    int
+	}{
+		{`false && boom()`, false, 0},
+		{`true || boom()`, true, 0},
+		{`true && boom()`, true, 1},
+		{`false || boom()`, true, 1},
+		{`boom() && boom() || boom()`, true, 2},
+	}
+	for _, tt := range tests {
+		env, seen := newRecordingEnvironment()
+		input := `let boom = fn() { record("boom"); true }; ` + tt.input
+		testBooleanObject(t, testEvalEnv(input, env), tt.expected)
+		if len(*seen) != tt.calls {
+			t.Errorf("%s: boom called %d times, want %d", tt.input, len(*seen), tt.calls)
+		}
+	}
+}
+
+func TestCoalesceOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+		calls    int
+	}{
+		{`1 ?? fallback()`, 1, 0},
+		{`false ?? fallback()`, false, 0},
+		{`{"a": 0}["a"] ?? fallback()`, 0, 0},
+		{`{"a": 0}["b"] ?? fallback()`, 42, 1},
+		{`if (false) { 1 } ?? fallback()`, 42, 1},
+		{`[][0] ?? [][1] ?? 7`, 7, 0},
+	}
+	for _, tt := range tests {
+		env, seen := newRecordingEnvironment()
+		input := `let fallback = fn() { record("fallback"); 42 }; ` + tt.input
+		evaluated := testEvalEnv(input, env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+		if len(*seen) != tt.calls {
+			t.Errorf("%s: fallback called %d times, want %d", tt.input, len(*seen), tt.calls)
+		}
+	}
+	testErrorObject(t, testEval(`missing ?? 1`), "identifier not found: missing")
+}
+
+func TestEvalWith(t *testing.T) {
+	vars := map[string]object.Object{
+		"x":    &object.Integer{Value: 10},
+		"name": &object.String{Value: "kd"},
+	}
+	result, err := evaluator.EvalWith("x * 2", vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testIntegerObject(t, result, 20)
+
+	result, err = evaluator.EvalWith(`"hi " + name`, vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if str, ok := result.(*object.String); !ok || str.Value != "hi kd" {
+		t.Errorf("wrong result. got=%#v", result)
+	}
+
+	result, err = evaluator.EvalWith("let y = x;", vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	testNullObject(t, result)
+
+	if _, err = evaluator.EvalWith("x + true", vars); err == nil ||
+		err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong runtime error. got=%v", err)
+	}
+	if _, err = evaluator.EvalWith("let y 1", vars); err == nil ||
+		err.Error() != "1:7: expected next token to be =, got INT instead" {
+		t.Errorf("wrong parse error. got=%v", err)
+	}
+	if result, err = evaluator.EvalWith("exit(2); x", vars); err == nil ||
+		err.Error() != "exit status 2" || result != nil {
+		t.Errorf("exit not reported. got=%v, %v", result, err)
+	}
+	if _, err = evaluator.EvalWith("y", vars); err == nil {
+		t.Errorf("let from an earlier EvalWith leaked into a later one")
+	}
+}
+
+func TestRun(t *testing.T) {
+	result := evaluator.Run(`puts("hello"); let x = 20; puts(x + 1); x * 2`)
+	testIntegerObject(t, result.Value, 40)
+	if result.Error != nil {
+		t.Errorf("unexpected error: %s", result.Error.Inspect())
+	}
+	if len(result.ParseErrors) != 0 {
+		t.Errorf("unexpected parse errors: %q", result.ParseErrors)
+	}
+	if result.Output != "hello\n21\n" {
+		t.Errorf("wrong output. got=%q", result.Output)
+	}
+
+	result = evaluator.Run(`puts("before"); 1 + true; puts("after")`)
+	if result.Value != nil {
+		t.Errorf("value set on error. got=%s", result.Value.Inspect())
+	}
+	testErrorObject(t, result.Error, "type mismatch: INTEGER + BOOLEAN")
+	if result.Output != "before\n" {
+		t.Errorf("wrong output. got=%q", result.Output)
+	}
+
+	testNullObject(t, evaluator.Run("let x = 1;").Value)
+
+	result = evaluator.Run(`puts("never"); let 1`)
+	if len(result.ParseErrors) == 0 || result.Output != "" || result.Value != nil {
+		t.Errorf("program with parse errors was run. got=%+v", result)
+	}
+
+	result = evaluator.Run(`puts("bye"); exit(2); puts("never")`)
+	if !result.Exited || result.ExitCode != 2 {
+		t.Errorf("exit not reported. got=%+v", result)
+	}
+	if result.Value != nil || result.Error != nil || result.Output != "bye\n" {
+		t.Errorf("wrong result after exit. got=%+v", result)
+	}
+	if result = evaluator.Run("exit(0)"); !result.Exited || result.ExitCode != 0 {
+		t.Errorf("exit(0) not reported. got=%+v", result)
+	}
+	if result = evaluator.Run("1"); result.Exited {
+		t.Errorf("Exited set for a program that didn't exit")
+	}
+}
@@ -0,0 +1,73 @@
+package test
+
+import (
+	"monkey_kd/lexer"
+	"monkey_kd/parser"
+	"monkey_kd/token"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func FuzzLexer(f *testing.F) {
+	seeds := []string{
+		"",
+		`let add = fn(x, y) { x + y; }; add(1.5, [2, 3][0]);`,
+		`{"a": "unterminated`,
+		"1..2 .5 5. é \xff\xfe\"",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		tokens := lexer.New(input).Tokens()
+		if len(tokens) > len(input)+1 {
+			t.Fatalf("lexer produced %d tokens for %d bytes", len(tokens), len(input))
+		}
+		for i, tok := range tokens[:len(tokens)-1] {
+			if tok.Type == token.EOF {
+				t.Fatalf("tokens[%d] is EOF before the end", i)
+			}
+			if tok.Offset >= tok.End || tok.End > len(input) {
+				t.Fatalf("tokens[%d] (%q) has bad span [%d,%d)", i, tok.Literal, tok.Offset, tok.End)
+			}
+		}
+		if tokens[len(tokens)-1].Type != token.EOF {
+			t.Fatalf("last token is not EOF")
+		}
+	})
+}
+
+func FuzzParser(f *testing.F) {
+	seeds := []string{
+		"",
+		`let add = fn(x, y) { x + y; }; add(1, 2);`,
+		`if (x < y) { x } else { y }`,
+		`{"a": [1, 2.5, fn() { return; }], "b": !-x}.a[0]`,
+		`let = ; if ( { fn( [ ] ) }`,
+		strings.Repeat("(", 5000),
+		strings.Repeat("[", 5000),
+		strings.Repeat("-", 5000) + "1",
+		"!;=",
+		"-)=1",
+		"[)] = 1",
+		"x + ) = 1",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		program, errors := parser.Parse(input)
+		if program == nil {
+			t.Fatalf("Parse returned a nil program")
+		}
+		for i, stmt := range program.Statements {
+			if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+				t.Fatalf("statement %d is nil", i)
+			}
+		}
+		if len(errors) == 0 {
+			_ = program.String()
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"monkey_kd/interpreter"
+	"monkey_kd/object"
+	"testing"
+)
+
+func TestInterpreterRun(t *testing.T) {
+	result, errors := interpreter.Run("let add = fn(a, b) { a + b }; add(2, 3)")
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %q", errors)
+	}
+	testIntegerObject(t, result, 5)
+
+	result, errors = interpreter.Run("let x = 1;")
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %q", errors)
+	}
+	testNullObject(t, result)
+
+	result, _ = interpreter.Run("1 + true")
+	testErrorObject(t, result, "type mismatch: INTEGER + BOOLEAN")
+
+	result, errors = interpreter.Run("let x 1; puts(x)")
+	if result != nil {
+		t.Errorf("program with parse errors was evaluated. got=%v", result)
+	}
+	if len(errors) != 1 || errors[0] != "1:7: expected next token to be =, got INT instead" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+
+	// Each Run starts afresh, so x from an earlier call is gone.
+	result, _ = interpreter.Run("x")
+	testErrorObject(t, result, "identifier not found: x")
+}
+
+func TestInterpreterRunEnv(t *testing.T) {
+	env := object.NewEnvironment()
+	for _, input := range []string{"let x = 2;", "let double = fn(n) { n * 2 };", "x += 1"} {
+		if _, errors := interpreter.RunEnv(input, env); len(errors) != 0 {
+			t.Fatalf("%q: unexpected errors: %q", input, errors)
+		}
+	}
+	result, errors := interpreter.RunEnv("double(x)", env)
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors: %q", errors)
+	}
+	testIntegerObject(t, result, 6)
+}
@@ -55,7 +55,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -66,6 +66,8 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+"foobar"
+"foo bar"
 `
 
 	tests := []LexTest{
@@ -142,8 +144,297 @@ if (5 < 10) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
 		{token.EOF, ""},
 	}
 
 	testLexer(t, input, tests)
 }
+
+func TestNextTokenFloat(t *testing.T) {
+	input := `3.14 10. 0.5`
+	tests := []LexTest{
+		{token.FLOAT, "3.14"},
+		{token.INT, "10"},
+		{token.DOT, "."},
+		{token.FLOAT, "0.5"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenLogicalOperators(t *testing.T) {
+	input := `a && b || c & d | e`
+	tests := []LexTest{
+		{token.IDENTIFIER, "a"},
+		{token.AND, "&&"},
+		{token.IDENTIFIER, "b"},
+		{token.OR, "||"},
+		{token.IDENTIFIER, "c"},
+		{token.ILLEGAL, "&"},
+		{token.IDENTIFIER, "d"},
+		{token.ILLEGAL, "|"},
+		{token.IDENTIFIER, "e"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenPrefixedIntegers(t *testing.T) {
+	input := `0xFF 0b101 0o17 0xZZ 0x; 0.5 07`
+	tests := []LexTest{
+		{token.INT, "0xFF"},
+		{token.INT, "0b101"},
+		{token.INT, "0o17"},
+		{token.INT, "0xZZ"},
+		{token.INT, "0x"},
+		{token.SEMICOLON, ";"},
+		{token.FLOAT, "0.5"},
+		{token.INT, "07"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenCoalesce(t *testing.T) {
+	input := `a ?? b ? c`
+	tests := []LexTest{
+		{token.IDENTIFIER, "a"},
+		{token.COALESCE, "??"},
+		{token.IDENTIFIER, "b"},
+		{token.ILLEGAL, "?"},
+		{token.IDENTIFIER, "c"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenCompoundAssignment(t *testing.T) {
+	input := `x += 1; x -= 2; x *= 3; x /= 4; x + = 5`
+	tests := []LexTest{
+		{token.IDENTIFIER, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.PLUS, "+"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenStringEscapes(t *testing.T) {
+	input := `"say \"hi\"" "back\\" "\u{1F600}"`
+	tests := []LexTest{
+		{token.STRING, `say \"hi\"`},
+		{token.STRING, `back\\`},
+		{token.STRING, `\u{1F600}`},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenUnterminatedString(t *testing.T) {
+	input := `x = "abc`
+	tests := []LexTest{
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.ILLEGAL, `"abc`},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+
+	_, errors := lexer.Tokenize(`"\"`)
+	if len(errors) != 1 || errors[0].Error() != "unterminated string literal" {
+		t.Errorf("wrong errors. got=%+v", errors)
+	}
+}
+
+func TestNextTokenRange(t *testing.T) {
+	input := `1..5 1.5 a..b 1. .5`
+	tests := []LexTest{
+		{token.INT, "1"},
+		{token.RANGE, ".."},
+		{token.INT, "5"},
+		{token.FLOAT, "1.5"},
+		{token.IDENTIFIER, "a"},
+		{token.RANGE, ".."},
+		{token.IDENTIFIER, "b"},
+		{token.INT, "1"},
+		{token.DOT, "."},
+		{token.DOT, "."},
+		{token.INT, "5"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestNextTokenLineComments(t *testing.T) {
+	plain, _ := lexer.Tokenize("let x = 5;")
+	tests := []string{
+		"let x = 5; // five",
+		"// leading\nlet x = 5;",
+		"let x = // inline\n5;//",
+		"let x = 5; //",
+	}
+	for _, input := range tests {
+		tokens, _ := lexer.Tokenize(input)
+		if len(tokens) != len(plain) {
+			t.Fatalf("%q: wrong number of tokens. expected=%d, got=%d",
+				input, len(plain), len(tokens))
+		}
+		for i := range tokens {
+			if tokens[i].Type != plain[i].Type || tokens[i].Literal != plain[i].Literal {
+				t.Errorf("%q: tokens[%d] wrong. expected=%+v, got=%+v",
+					input, i, plain[i], tokens[i])
+			}
+		}
+	}
+
+	testLexer(t, "10 / 2 // half", []LexTest{
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.EOF, ""},
+	})
+}
+
+func TestNextTokenBlockComments(t *testing.T) {
+	testLexer(t, "let /* the\nanswer */ x = /**/ 42; /* trailing */", []LexTest{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "42"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	})
+
+	// Block comments don't nest: the first */ ends the comment.
+	testLexer(t, "/* a /* b */ c */", []LexTest{
+		{token.IDENTIFIER, "c"},
+		{token.ASTERISK, "*"},
+		{token.SLASH, "/"},
+		{token.EOF, ""},
+	})
+
+	testLexer(t, "1 /* never closed", []LexTest{
+		{token.INT, "1"},
+		{token.ILLEGAL, "/* never closed"},
+		{token.EOF, ""},
+	})
+	_, errors := lexer.Tokenize("/*/")
+	if len(errors) != 1 || errors[0].Error() != "unterminated block comment" {
+		t.Errorf("wrong errors. got=%+v", errors)
+	}
+}
+
+func TestNextTokenUnicodeWhitespace(t *testing.T) {
+	input := "let\u00a0x\u00a0=\u00a05;\u3000x\u2028"
+	tests := []LexTest{
+		{token.LET, "let"},
+		{token.IDENTIFIER, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.IDENTIFIER, "x"},
+		{token.EOF, ""},
+	}
+	testLexer(t, input, tests)
+}
+
+func TestTokenize(t *testing.T) {
+	input := `let add = fn(x, y) { x + y; }; add(1, 2) @ #`
+	tokens, errors := lexer.Tokenize(input)
+
+	lex := lexer.New(input)
+	expected := []token.Token{}
+	for {
+		tok := lex.NextToken()
+		expected = append(expected, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d",
+			len(expected), len(tokens))
+	}
+	for i, tok := range tokens {
+		if tok != expected[i] {
+			t.Errorf("tokens[%d] wrong. expected=%+v, got=%+v", i, expected[i], tok)
+		}
+	}
+	if len(errors) != 2 {
+		t.Fatalf("wrong number of errors. expected=2, got=%d", len(errors))
+	}
+	if errors[0].Error() != `illegal character "@"` || errors[1].Token.Literal != "#" {
+		t.Errorf("wrong errors. got=%+v", errors)
+	}
+}
+
+func TestTokenOffsets(t *testing.T) {
+	input := `x == "ab"`
+	tokens, _ := lexer.Tokenize(input)
+	expected := []struct {
+		offset int
+		end    int
+	}{
+		{0, 1},
+		{2, 4},
+		{5, 9},
+		{9, 9},
+	}
+	for i, tt := range expected {
+		if tokens[i].Offset != tt.offset || tokens[i].End != tt.end {
+			t.Errorf("tokens[%d] (%q) span wrong. expected=[%d,%d), got=[%d,%d)",
+				i, tokens[i].Literal, tt.offset, tt.end, tokens[i].Offset, tokens[i].End)
+		}
+	}
+}
+
+func TestTokenLineAndColumn(t *testing.T) {
+	input := "let x = 5;\n  \"é\" + x\n\n\"a\nb\" y"
+	tokens, _ := lexer.Tokenize(input)
+	expected := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"let", 1, 1},
+		{"x", 1, 5},
+		{"=", 1, 7},
+		{"5", 1, 9},
+		{";", 1, 10},
+		{"é", 2, 3},
+		{"+", 2, 7},
+		{"x", 2, 9},
+		{"a\nb", 4, 1},
+		{"y", 5, 4},
+		{"", 5, 5},
+	}
+	if len(tokens) != len(expected) {
+		t.Fatalf("wrong number of tokens. expected=%d, got=%d", len(expected), len(tokens))
+	}
+	for i, tt := range expected {
+		tok := tokens[i]
+		if tok.Literal != tt.literal || tok.Line != tt.line || tok.Column != tt.column {
+			t.Errorf("tokens[%d] wrong. expected=%q at %d:%d, got=%q at %d:%d",
+				i, tt.literal, tt.line, tt.column, tok.Literal, tok.Line, tok.Column)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"monkey_kd/object"
+	"testing"
+)
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{0.1, "0.1"},
+		{-2.5, "-2.5"},
+		{2, "2.0"},
+		{0, "0.0"},
+		{-7, "-7.0"},
+		{1e21, "1e+21"},
+		{1.5e300, "1.5e+300"},
+		{1e-7, "1e-07"},
+	}
+	for _, tt := range tests {
+		f := &object.Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("Float{%v}.Inspect() wrong. expected=%q, got=%q",
+				tt.value, tt.expected, f.Inspect())
+		}
+	}
+	integer := &object.Integer{Value: 2}
+	if integer.Inspect() == (&object.Float{Value: 2}).Inspect() {
+		t.Errorf("integer and float 2 inspect the same: %q", integer.Inspect())
+	}
+}
+
+func TestIntegerInspectGrouped(t *testing.T) {
+	tests := []struct {
+		value    int64
+		sep      string
+		expected string
+	}{
+		{1234567, "", "1234567"},
+		{1234567, ",", "1,234,567"},
+		{1234567, "_", "1_234_567"},
+		{-1234567, ",", "-1,234,567"},
+		{123, ",", "123"},
+		{123456, ",", "123,456"},
+		{0, ",", "0"},
+	}
+	for _, tt := range tests {
+		integer := &object.Integer{Value: tt.value}
+		if got := integer.InspectGrouped(tt.sep); got != tt.expected {
+			t.Errorf("InspectGrouped(%q) of %d wrong. expected=%q, got=%q",
+				tt.sep, tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestEnvironmentInspectDigitSeparator(t *testing.T) {
+	env := object.NewEnvironment()
+	value := &object.Array{Elements: []object.Object{
+		&object.Integer{Value: 1234567},
+		&object.Float{Value: 2.5},
+	}}
+	if got := env.Inspect(value); got != "[1234567, 2.5]" {
+		t.Errorf("plain Inspect wrong. got=%q", got)
+	}
+	env.Options().DigitSeparator = ","
+	if got := env.Inspect(value); got != "[1,234,567, 2.5]" {
+		t.Errorf("grouped Inspect wrong. got=%q", got)
+	}
+	if got := value.Inspect(); got != "[1234567, 2.5]" {
+		t.Errorf("object Inspect changed. got=%q", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"monkey_kd/ast"
 	"monkey_kd/lexer"
 	"monkey_kd/parser"
+	"strings"
 	"testing"
 )
 
@@ -166,6 +167,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 	}{
 		{"!5;", "!", 5},
 		{"-15;", "-", 15},
+		{"+5;", "+", 5},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
 	}
@@ -281,10 +283,66 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"-a * b",
 			"((-a) * b)",
 		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"1..n + 1",
+			"(1 .. (n + 1))",
+		},
+		{
+			"a..b == c",
+			"((a .. b) == c)",
+		},
+		{
+			"a || b && c == d",
+			"(a || (b && (c == d)))",
+		},
+		{
+			"x + 1 in xs == true",
+			"(((x + 1) in xs) == true)",
+		},
+		{
+			"!a in b",
+			"((!a) in b)",
+		},
+		{
+			"a && b || c",
+			"((a && b) || c)",
+		},
+		{
+			"a ?? b || c",
+			"(a ?? (b || c))",
+		},
+		{
+			"a ?? b ?? c",
+			"((a ?? b) ?? c)",
+		},
+		{
+			"x = a[0] ?? 1",
+			"(x = ((a[0]) ?? 1))",
+		},
 		{
 			"!-a",
 			"(!(-a))",
 		},
+		{
+			"!!a",
+			"(!(!a))",
+		},
+		{
+			"!!a == b",
+			"((!(!a)) == b)",
+		},
+		{
+			"+a * b",
+			"((+a) * b)",
+		},
+		{
+			"a + +b",
+			"(a + (+b))",
+		},
 		{
 			"a + b + c",
 			"((a + b) + c)",
@@ -373,6 +431,38 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"arr[f()]",
+			"(arr[f()])",
+		},
+		{
+			"f()[0]",
+			"(f()[0])",
+		},
+		{
+			"a.b.c",
+			"((a.b).c)",
+		},
+		{
+			"data[\"users\"][0].name + 1",
+			"((((data[\"users\"])[0]).name) + 1)",
+		},
+		{
+			"-a.b",
+			"(-(a.b))",
+		},
+		{
+			"f(x)[0](y)",
+			"(f(x)[0])(y)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -503,6 +593,53 @@ func TestIfExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	program, errors := parser.Parse(`if (x < y) { x } else if (y < z) { y } else { z }`)
+	if len(errors) != 0 {
+		t.Fatalf("parser has %d errors: %q", len(errors), errors)
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative does not hold 1 statement. got=%+v", exp.Alternative)
+	}
+	inner, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Alternative.Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Alternative.Statements[0])
+	}
+	elseIf, ok := inner.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("else branch is not ast.IfExpression. got=%T", inner.Expression)
+	}
+	if !testInfixExpression(t, elseIf.Condition, "y", "<", "z") {
+		return
+	}
+	if elseIf.Alternative == nil {
+		t.Errorf("elseIf.Alternative is nil")
+	}
+}
+
+func TestElseWithoutBlockOrIf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (x) { 1 } else 2", "1:19: expected { or if after else, got INT instead"},
+		{"if (x) { 1 } else", "1:18: expected { or if after else, got EOF instead"},
+	}
+	for _, tt := range tests {
+		_, errors := parser.Parse(tt.input)
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong first error. expected=%q, got=%q",
+				tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 	lex := lexer.New(input)
@@ -598,3 +735,500 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+	lex := lexer.New(input)
+	parse := parser.New(lex)
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestParsingArrayLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		length   int
+		expected string
+	}{
+		{"[]", 0, "[]"},
+		{"[1]", 1, "[1]"},
+		{"[1, 2, 3]", 3, "[1, 2, 3]"},
+		{"[[1], []]", 2, "[[1], []]"},
+	}
+	for _, tt := range tests {
+		program, errors := parser.Parse(tt.input)
+		if len(errors) != 0 {
+			t.Fatalf("%s: parser has errors: %q", tt.input, errors)
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		array, ok := stmt.Expression.(*ast.ArrayLiteral)
+		if !ok {
+			t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+		}
+		if len(array.Elements) != tt.length {
+			t.Errorf("%s: len(array.Elements) wrong. expected=%d, got=%d",
+				tt.input, tt.length, len(array.Elements))
+		}
+		if array.String() != tt.expected {
+			t.Errorf("array.String() wrong. expected=%q, got=%q", tt.expected, array.String())
+		}
+	}
+}
+
+func TestTrailingCommaErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, 2,]", "1:7: unexpected trailing comma before ]"},
+		{"f(1,)", "1:5: unexpected trailing comma before )"},
+	}
+	for _, tt := range tests {
+		_, errors := parser.Parse(tt.input)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. expected=[%q], got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestStopOnFirstError(t *testing.T) {
+	input := `let x = 1;
+let = 2;
+let y 3;
+let z = 4;`
+	parse := parser.New(lexer.New(input))
+	program := parse.ParseProgram()
+	if len(parse.Errors()) < 2 {
+		t.Fatalf("expected several errors by default. got=%q", parse.Errors())
+	}
+
+	parse = parser.New(lexer.New(input))
+	parse.StopOnFirstError = true
+	program = parse.ParseProgram()
+	errors := parse.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected exactly 1 error. got=%d (%q)", len(errors), errors)
+	}
+	if errors[0] != "2:5: expected next token to be IDENTIFIER, got = instead" {
+		t.Errorf("wrong error. got=%q", errors[0])
+	}
+	if len(program.Statements) != 1 {
+		t.Fatalf("program not terminated early. got=%d statements",
+			len(program.Statements))
+	}
+	testLetStatement(t, program.Statements[0], "x")
+}
+
+func TestParsingNestedHashLiteral(t *testing.T) {
+	input := `{"a": {"b": [1, true]}, "c": false}`
+	parse := parser.New(lexer.New(input))
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	inner, ok := hash.Pairs[hash.Keys[0]].(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("value of \"a\" is not ast.HashLiteral. got=%T", hash.Pairs[hash.Keys[0]])
+	}
+	array, ok := inner.Pairs[inner.Keys[0]].(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("value of \"b\" is not ast.ArrayLiteral. got=%T", inner.Pairs[inner.Keys[0]])
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testBooleanLiteral(t, array.Elements[1], true)
+	testBooleanLiteral(t, hash.Pairs[hash.Keys[1]], false)
+	if hash.String() != `{"a":{"b":[1, true]}, "c":false}` {
+		t.Errorf("hash.String() wrong. got=%q", hash.String())
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+	parse := parser.New(lexer.New(input))
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestTruncatedCallExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"foo(1, 2", "1:9: expected next token to be ), got EOF instead"},
+		{"let x = foo(1, 2", "1:17: expected next token to be ), got EOF instead"},
+		{"return foo(1", "1:13: expected next token to be ), got EOF instead"},
+		{"let x = 5", ""},
+	}
+	for _, tt := range tests {
+		parse := parser.New(lexer.New(tt.input))
+		parse.ParseProgram()
+		errors := parse.Errors()
+		if tt.expected == "" {
+			if len(errors) != 0 {
+				t.Errorf("%q: unexpected errors %q", tt.input, errors)
+			}
+			continue
+		}
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong errors. expected=[%q], got=%q",
+				tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParseStatementSpans(t *testing.T) {
+	input := "let a = \"hi\";\n  add(a, 2)\n"
+	spans := parser.New(lexer.New(input)).ParseStatementSpans()
+	expected := []string{`let a = "hi";`, "add(a, 2)"}
+	if len(spans) != len(expected) {
+		t.Fatalf("wrong number of spans. expected=%d, got=%d", len(expected), len(spans))
+	}
+	for i, span := range spans {
+		if got := input[span.Start:span.End]; got != expected[i] {
+			t.Errorf("spans[%d] covers %q, want %q", i, got, expected[i])
+		}
+	}
+	if spans[0].Start != 0 || spans[0].End != 13 || spans[1].Start != 16 || spans[1].End != 25 {
+		t.Errorf("wrong offsets. got=%+v", spans)
+	}
+	testLetStatement(t, spans[0].Statement, "a")
+}
+
+func TestParsingMethodCall(t *testing.T) {
+	parse := parser.New(lexer.New(`"abc".len()`))
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	call, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.CallExpression. got=%T", stmt.Expression)
+	}
+	dot, ok := call.Function.(*ast.DotExpression)
+	if !ok {
+		t.Fatalf("call.Function not *ast.DotExpression. got=%T", call.Function)
+	}
+	if dot.Property.Value != "len" || len(call.Arguments) != 0 {
+		t.Errorf("wrong method call. got=%s", call.String())
+	}
+}
+
+func TestNestingDepthLimit(t *testing.T) {
+	input := strings.Repeat("(", 10000) + "1" + strings.Repeat(")", 10000)
+	_, errors := parser.Parse(input)
+	if len(errors) == 0 {
+		t.Fatalf("expected errors for deeply nested input")
+	}
+	expected := "1:513: expression nested too deeply (more than 512 levels)"
+	if errors[0] != expected {
+		t.Errorf("wrong first error. expected=%q, got=%q", expected, errors[0])
+	}
+
+	program, errors := parser.Parse(strings.Repeat("(", 100) + "1" + strings.Repeat(")", 100))
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %q", errors)
+	}
+	if program.String() != "1" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+}
+
+func TestStringLiteralEscapes(t *testing.T) {
+	tests := []struct {
+		input     string
+		value     string
+		canonical string
+	}{
+		{`"plain"`, "plain", `"plain"`},
+		{`"line\nbreak"`, "line\nbreak", `"line\nbreak"`},
+		{`"tab\t\"q\" \\"`, "tab\t\"q\" \\", `"tab\t\"q\" \\"`},
+		{`"\x41\u{e9}\u{1F600}"`, "A\u00e9\U0001F600", "\"Aé\U0001F600\""},
+		{`"\x01\u{7f}\xff"`, "\x01\x7f\xff", `"\u{1}\u{7f}\xff"`},
+	}
+	for _, tt := range tests {
+		program, errors := parser.Parse(tt.input)
+		if len(errors) != 0 {
+			t.Fatalf("%s: parser has errors: %q", tt.input, errors)
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.value {
+			t.Errorf("%s: literal.Value wrong. expected=%q, got=%q",
+				tt.input, tt.value, literal.Value)
+		}
+		if literal.String() != tt.canonical {
+			t.Errorf("%s: literal.String() wrong. expected=%q, got=%q",
+				tt.input, tt.canonical, literal.String())
+		}
+
+		// The canonical form must parse back to the same value.
+		reparsed, errors := parser.Parse(literal.String())
+		if len(errors) != 0 {
+			t.Fatalf("%s: reparse has errors: %q", literal.String(), errors)
+		}
+		again := reparsed.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+		if again.Value != literal.Value {
+			t.Errorf("%s: round trip changed value. expected=%q, got=%q",
+				tt.input, literal.Value, again.Value)
+		}
+	}
+}
+
+func TestStringLiteralInvalidEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\q"`, `1:1: invalid escape sequence "\\q" in string literal`},
+		{`"\x4"`, `1:1: invalid escape sequence "\\x4" in string literal`},
+		{`"\xzz"`, `1:1: invalid escape sequence "\\xzz" in string literal`},
+		{`"\u{110000}"`, `1:1: invalid escape sequence "\\u{110000}" in string literal`},
+		{`"\u41"`, `1:1: invalid escape sequence "\\u" in string literal`},
+	}
+	for _, tt := range tests {
+		_, errors := parser.Parse(tt.input)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. expected=[%q], got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestStringLiteralInLetStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let greeting = "hi";`, "hi"},
+		{`let empty = "";`, ""},
+	}
+	for _, tt := range tests {
+		program, errors := parser.Parse(tt.input)
+		if len(errors) != 0 {
+			t.Fatalf("%s: parser has errors: %q", tt.input, errors)
+		}
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not *ast.LetStatement. got=%T",
+				program.Statements[0])
+		}
+		literal, ok := stmt.Value.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("stmt.Value is not *ast.StringLiteral. got=%T", stmt.Value)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value wrong. expected=%q, got=%q", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestIllegalTokenErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = "abc`, "1:9: unterminated string literal"},
+		{`"`, "1:1: unterminated string literal"},
+		{`let x = @;`, `1:9: illegal character "@"`},
+	}
+	for _, tt := range tests {
+		_, errors := parser.Parse(tt.input)
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%s: wrong errors. expected=[%q], got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	program, errors := parser.Parse(`let h = {};`)
+	if len(errors) != 0 {
+		t.Fatalf("parser has errors: %q", errors)
+	}
+	stmt := program.Statements[0].(*ast.LetStatement)
+	hash, ok := stmt.Value.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.HashLiteral. got=%T", stmt.Value)
+	}
+	if len(hash.Pairs) != 0 || len(hash.Keys) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestWhileParsing(t *testing.T) {
+	program, errors := parser.Parse(`while (x < y) { x }; let v = while (a) { b };`)
+	if len(errors) != 0 {
+		t.Fatalf("parser has errors: %q", errors)
+	}
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Loop.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Loop.Body.Statements) != 1 {
+		t.Errorf("body is not 1 statement. got=%d", len(stmt.Loop.Body.Statements))
+	}
+	let := program.Statements[1].(*ast.LetStatement)
+	if _, ok := let.Value.(*ast.WhileExpression); !ok {
+		t.Errorf("let value is not ast.WhileExpression. got=%T", let.Value)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1;\nlet y 2;", "2:7: expected next token to be =, got INT instead"},
+		{"let a = 1;\n\n  let b = );", "3:11: no prefix parse function for ) found"},
+	}
+	for _, tt := range tests {
+		_, errors := parser.Parse(tt.input)
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%q: wrong first error. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestSemicolonInsertionWarnings(t *testing.T) {
+	input := `let x = 1
+let y = x + 2;
+return y
+puts(y); if (x) { x }
+`
+	parse := parser.New(lexer.New(input))
+	parse.ParseProgram()
+	checkParserErrors(t, parse)
+	if len(parse.Warnings()) != 0 {
+		t.Fatalf("expected no warnings by default. got=%q", parse.Warnings())
+	}
+
+	parse = parser.New(lexer.New(input))
+	parse.WarnSemicolonInsertion = true
+	program := parse.ParseProgram()
+	checkParserErrors(t, parse)
+	if len(program.Statements) != 5 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+	expected := []string{
+		`1:9: semicolon inserted after "1"`,
+		`3:8: semicolon inserted after "y"`,
+	}
+	warnings := parse.Warnings()
+	if len(warnings) != len(expected) {
+		t.Fatalf("wrong warnings. expected=%q, got=%q", expected, warnings)
+	}
+	for i, want := range expected {
+		if warnings[i] != want {
+			t.Errorf("warnings[%d] wrong. expected=%q, got=%q", i, want, warnings[i])
+		}
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = x + 1", "(x = (x + 1))"},
+		{"a = b = 1", "(a = (b = 1))"},
+		{"a = b || c", "(a = (b || c))"},
+		{"f(x = 2)", "f((x = 2))"},
+		{"x += y * 2", "(x += (y * 2))"},
+		{"a -= b /= 2", "(a -= (b /= 2))"},
+	}
+	for _, tt := range tests {
+		program, errors := parser.Parse(tt.input)
+		if len(errors) != 0 {
+			t.Errorf("%q: parser has errors: %q", tt.input, errors)
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	_, errors := parser.Parse("1 = 2;")
+	if len(errors) == 0 || errors[0] != "1:3: cannot assign to 1" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+	_, errors = parser.Parse("a + b = 1;")
+	if len(errors) == 0 || errors[0] != "1:7: cannot assign to (a + b)" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+
+	// Left sides that failed to parse are incomplete; assigning to them
+	// must report their errors rather than panic.
+	for _, input := range []string{"!;=", "-)=1", "[)] = 1", "x + ) = 1"} {
+		if _, errors := parser.Parse(input); len(errors) == 0 {
+			t.Errorf("%q: expected parse errors", input)
+		}
+	}
+
+	// An earlier, unrelated error doesn't hide an invalid target.
+	_, errors = parser.Parse("let = 1; [1] = 2;")
+	if len(errors) != 3 || errors[2] != "1:14: cannot assign to [1]" {
+		t.Errorf("wrong errors. got=%q", errors)
+	}
+}
+
+func TestDoubleBangParsing(t *testing.T) {
+	program, errors := parser.Parse("!!x")
+	if len(errors) != 0 {
+		t.Fatalf("parser has errors: %q", errors)
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	outer, ok := stmt.Expression.(*ast.PrefixExpression)
+	if !ok || outer.Operator != "!" {
+		t.Fatalf("outer expression is not a ! PrefixExpression. got=%T (%s)",
+			stmt.Expression, stmt.Expression)
+	}
+	inner, ok := outer.Right.(*ast.PrefixExpression)
+	if !ok || inner.Operator != "!" {
+		t.Fatalf("inner expression is not a ! PrefixExpression. got=%T (%s)",
+			outer.Right, outer.Right)
+	}
+	testIdentifier(t, inner.Right, "x")
+}
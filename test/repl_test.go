@@ -0,0 +1,300 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"monkey_kd/object"
+	"monkey_kd/repl"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runRepl(input string) string {
+	var out bytes.Buffer
+	repl.Start(strings.NewReader(input), &out)
+	return out.String()
+}
+
+func TestReplEvaluatesLines(t *testing.T) {
+	output := runRepl("let x = 2;\nx * 21\n")
+	if !strings.Contains(output, "42\n") {
+		t.Errorf("output does not contain result. got=%q", output)
+	}
+}
+
+func TestReplHelpCommand(t *testing.T) {
+	output := runRepl(":help\n")
+	names := repl.CommandNames()
+	if len(names) == 0 {
+		t.Fatalf("no commands registered")
+	}
+	for _, name := range names {
+		if !strings.Contains(output, ":"+name) {
+			t.Errorf(":help output missing %q. got=%q", ":"+name, output)
+		}
+	}
+}
+
+func TestReplUnknownCommand(t *testing.T) {
+	output := runRepl(":nope\n")
+	if !strings.Contains(output, "type :help for a list") {
+		t.Errorf("unknown command not reported. got=%q", output)
+	}
+}
+
+func TestReplLineContinuation(t *testing.T) {
+	output := runRepl("let x = 1 +\\\n2; x * 10\n")
+	if !strings.Contains(output, "30\n") {
+		t.Errorf("continued line not evaluated as one. got=%q", output)
+	}
+	output = runRepl("\"a\" \\\\\n2\n")
+	if !strings.HasSuffix(output, "\n2\n") {
+		t.Errorf("escaped backslash treated as continuation. got=%q", output)
+	}
+}
+
+func TestReplCheckMode(t *testing.T) {
+	output := runRepl(":check on\nlet x = 1 + 2; x\nlet = 5;\n:check off\nx\n")
+	lines := strings.Split(output, "\n")
+	expected := []string{"ok", "expected next token to be IDENTIFIER, got = instead"}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q. got=%q", want, output)
+		}
+	}
+	if strings.Contains(output, "3\n") {
+		t.Errorf("input was evaluated in check mode. got=%q", output)
+	}
+	last := lines[len(lines)-2]
+	if !strings.Contains(last, "identifier not found: x") {
+		t.Errorf("let ran in check mode or evaluation not resumed. got=%q", last)
+	}
+	output = runRepl(":check maybe\n")
+	if !strings.Contains(output, "usage: :check on|off") {
+		t.Errorf("bad argument not reported. got=%q", output)
+	}
+}
+
+func TestReplMultiLineInput(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[\n1,\n2\n]\n", "[1, 2]\n"},
+		{"{\"a\":\n[1,\n2]}\n", "{a: [1, 2]}\n"},
+		{"let add = fn(x, y) {\n  x + y\n};\nadd(\n1,\n2)\n", "3\n"},
+		{"\"(\"\n", "(\n"},
+	}
+	for _, tt := range tests {
+		output := runRepl(tt.input)
+		if !strings.Contains(output, tt.expected) {
+			t.Errorf("multi-line input %q not evaluated as one. got=%q",
+				tt.input, output)
+		}
+		if strings.Contains(output, "expected next token") {
+			t.Errorf("incomplete input was parsed early. got=%q", output)
+		}
+	}
+}
+
+func TestReplBlankLineFlushesInput(t *testing.T) {
+	output := runRepl("[1,\n2\n\n1 + 2\n")
+	lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected an error line and a result line. got=%q", output)
+	}
+	if !strings.Contains(lines[0], "expected next token to be ]") {
+		t.Errorf("unfinished input not reported. got=%q", lines[0])
+	}
+	if lines[1] != "3" {
+		t.Errorf("input after the blank line not evaluated on its own. got=%q", lines[1])
+	}
+}
+
+func TestReplNoPromptWhenPiped(t *testing.T) {
+	output := runRepl("1 + 1\nlet f = fn() {\n1\n}\n")
+	if strings.Contains(output, repl.PROMPT) || strings.Contains(output, repl.CONTINUATION_PROMPT) {
+		t.Errorf("prompt printed for non-terminal input. got=%q", output)
+	}
+	if output != "2\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+}
+
+func TestReplTopLevelReturn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 42;\n", "42\n"},
+		{"if (true) { return 42; }\n", "42\n"},
+		{"let f = fn() { return [1, 2]; }; return f();\n", "[1, 2]\n"},
+	}
+	for _, tt := range tests {
+		output := runRepl(tt.input)
+		if output != tt.expected {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.input, tt.expected, output)
+		}
+	}
+}
+
+func TestReplStepMode(t *testing.T) {
+	output := runRepl(":step on\nlet x = 6; x * 7\n\nx\n")
+	if output != "42\n6\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+
+	// Whatever is typed at the pause is consumed by it, not evaluated.
+	output = runRepl(":step on\n1; 2\n100\n:step off\n3\n")
+	if output != "1\n2\n3\n" {
+		t.Errorf("unexpected output. got=%q", output)
+	}
+
+	output = runRepl(":step on\n1; x; 3\n")
+	if output != "1\nERROR: identifier not found: x\n" {
+		t.Errorf("step mode did not stop at an error. got=%q", output)
+	}
+}
+
+func TestReplDigitsCommand(t *testing.T) {
+	output := runRepl("1000000\n:digits underscore\n1000000\n:digits plain\n1000000\n:digits dots\n")
+	expected := "1000000\n1_000_000\n1000000\nusage: :digits plain|comma|underscore\n"
+	if output != expected {
+		t.Errorf("unexpected output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestReplPutsInterleaves(t *testing.T) {
+	output := runRepl("1\nputs(\"a\", \"b\")\n2\n")
+	expected := "1\na\nb\nnull\n2\n"
+	if output != expected {
+		t.Errorf("unexpected output. expected=%q, got=%q", expected, output)
+	}
+}
+
+func TestReplExit(t *testing.T) {
+	output := runRepl("1\nexit()\n2\n")
+	if output != "1\n" {
+		t.Errorf("REPL kept evaluating after exit. got=%q", output)
+	}
+}
+
+func runFile(t *testing.T, src string) (string, error) {
+	path := filepath.Join(t.TempDir(), "script.monkey")
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	err := repl.RunFile(path, &out)
+	return out.String(), err
+}
+
+func TestRunFile(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"let x = 2;\nlet double = fn(n) { n * 2 };\ndouble(x) + 1\n", "5\n"},
+		{"puts(\"hi\");\nputs(\"there\");\n", "hi\nthere\n"},
+		{"let x = 1;\n", ""},
+		{"puts(\"bye\"); exit(0); puts(\"never\")", "bye\n"},
+	}
+	for _, tt := range tests {
+		output, err := runFile(t, tt.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.src, err)
+		}
+		if output != tt.expected {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.src, tt.expected, output)
+		}
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		output   string
+		expected string
+	}{
+		{"puts(1);\nx + true;\nputs(\"never\")\n", "1\n",
+			"script.monkey: identifier not found: x"},
+		{"let x = 1;\nlet y 2;\nputs(\"never\")\n",
+			"\t2:7: expected next token to be =, got INT instead\n",
+			"script.monkey: 1 parse error(s)"},
+		// Every parse error carries its position, not just the syntax ones.
+		{"let s = \"abc\\q\";\n  99999999999999999999;\n[1,]",
+			"\t1:9: invalid escape sequence \"\\\\q\" in string literal\n" +
+				"\t2:3: integer literal 99999999999999999999 out of range\n" +
+				"\t3:4: unexpected trailing comma before ]\n",
+			"script.monkey: 3 parse error(s)"},
+	}
+	for _, tt := range tests {
+		output, err := runFile(t, tt.src)
+		if output != tt.output {
+			t.Errorf("%q: wrong output. expected=%q, got=%q", tt.src, tt.output, output)
+		}
+		if err == nil || !strings.HasSuffix(err.Error(), tt.expected) {
+			t.Errorf("%q: wrong error. expected suffix %q, got=%v", tt.src, tt.expected, err)
+		}
+	}
+}
+
+func TestRunFileExitCode(t *testing.T) {
+	_, err := runFile(t, "exit(3); puts(\"never\")")
+	var exit *repl.ExitError
+	if !errors.As(err, &exit) || exit.Code != 3 {
+		t.Errorf("expected *repl.ExitError with code 3. got=%v", err)
+	}
+}
+
+func TestRunFileUnreadable(t *testing.T) {
+	var out bytes.Buffer
+	err := repl.RunFile(filepath.Join(t.TempDir(), "missing.monkey"), &out)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected a not-exist error. got=%v", err)
+	}
+}
+
+func TestReplStartWithEnv(t *testing.T) {
+	env := object.NewEnvironment()
+	env.Set("answer", &object.Integer{Value: 42})
+	var out bytes.Buffer
+	repl.StartWithEnv(strings.NewReader("answer + 1\nlet seen = true;\n"), &out, env)
+	if out.String() != "43\n" {
+		t.Errorf("seeded variable not visible. got=%q", out.String())
+	}
+	if _, ok := env.Get("seen"); !ok {
+		t.Errorf("bindings made in the REPL not kept in the host's environment")
+	}
+}
+
+func TestReplTokensCommand(t *testing.T) {
+	output := runRepl(":tokens on\nlet x = 2;\nx\n:tokens off\nx * 3\n:tokens maybe\n")
+	expected := []string{
+		"{Type:LET Literal:let Offset:0 End:3 Line:1 Column:1}",
+		"{Type:; Literal:; Offset:9 End:10 Line:1 Column:10}",
+		"{Type:IDENTIFIER Literal:x Offset:0 End:1 Line:1 Column:1}\n2\n",
+		"6\n",
+		"usage: :tokens on|off",
+	}
+	for _, want := range expected {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q. got=%q", want, output)
+		}
+	}
+	if strings.Count(output, "{Type:") != 6 {
+		t.Errorf("tokens printed while :tokens was off. got=%q", output)
+	}
+}
+
+func TestReplASTCommand(t *testing.T) {
+	output := runRepl(":ast on\n1 + 2 * 3\n:ast off\n4\n")
+	expected := "Program\n  ExpressionStatement\n    InfixExpression +\n" +
+		"      IntegerLiteral 1\n      InfixExpression *\n" +
+		"        IntegerLiteral 2\n        IntegerLiteral 3\n7\n4\n"
+	if output != expected {
+		t.Errorf("wrong output. expected=%q, got=%q", expected, output)
+	}
+}
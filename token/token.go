@@ -5,6 +5,14 @@ type TokenType string
 type Token struct {
 	Type TokenType
 	Literal string
+	// Offset and End delimit the token's source text as byte offsets
+	// [Offset, End), including any quotes or other syntax not in Literal.
+	Offset int
+	End int
+	// Line and Column locate the start of the token, both counting from 1;
+	// Column counts characters, not bytes.
+	Line int
+	Column int
 }
 
 const (
@@ -14,6 +22,8 @@ const (
 	/* Identifiers & literals */
 	IDENTIFIER = "IDENTIFIER"
 	INT = "INT"
+	FLOAT = "FLOAT"
+	STRING = "STRING"
 
 	/* Operators */
 	ASSIGN = "="
@@ -22,16 +32,22 @@ const (
 	BANG = "!"
 	ASTERISK = "*"
 	SLASH = "/"
+	PERCENT = "%"
 	LT = "<"
 	GT = ">"
 	
 	/* Delimiters */
 	COMMA = ","
 	SEMICOLON = ";"
+	COLON = ":"
+	DOT = "."
+	RANGE = ".."
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 	
 	/* Keywords */
 	FUNCTION = "FUNCTION"
@@ -40,10 +56,20 @@ const (
 	FALSE = "FALSE"
 	IF = "IF"
 	ELSE = "ELSE"
+	WHILE = "WHILE"
 	RETURN = "RETURN"
+	DEFER = "DEFER"
+	IN = "IN"
 
 	EQ = "=="
 	NOT_EQ = "!="
+	AND = "&&"
+	OR = "||"
+	COALESCE = "??"
+	PLUS_ASSIGN = "+="
+	MINUS_ASSIGN = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN = "/="
 )
 
 var keywords = map[string]TokenType{
@@ -53,7 +79,10 @@ var keywords = map[string]TokenType{
 	"false": FALSE,
 	"if": IF,
 	"else": ELSE,
+	"while": WHILE,
 	"return": RETURN,
+	"defer": DEFER,
+	"in": IN,
 }
 
 func LookupIdentifier(identifier string) TokenType {